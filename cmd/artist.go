@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// artistLimit caps how many songs per artist are kept, for -max-per-artist
+type artistLimit struct {
+	max     int
+	counts  map[string]int
+	trimmed int
+}
+
+// newArtistLimit creates a limit of max songs per artist
+func newArtistLimit(max int) *artistLimit {
+	return &artistLimit{max: max, counts: make(map[string]int)}
+}
+
+// allow reports whether a song is within its artist's limit, counting it if
+// so. Songs of unknown artist are always allowed.
+func (l *artistLimit) allow(s song) bool {
+	artist := songArtist(s)
+	if artist == "" {
+		return true
+	}
+	if l.counts[artist] >= l.max {
+		l.trimmed++
+		return false
+	}
+	l.counts[artist]++
+	return true
+}
+
+// report prints how many songs the limit left out
+func (l *artistLimit) report() {
+	if l.trimmed > 0 {
+		fmt.Printf("%sLeft out %d song(s) over -max-per-artist %d%s\n\n", colorDim, l.trimmed, l.max, colorReset)
+	}
+}
+
+// songArtist returns a song's main artist, normalized for comparison: the
+// first Spotify artist, else the artist of an "Artist - Title" query
+func songArtist(s song) string {
+	artist := ""
+	switch {
+	case s.Track != nil:
+		artist, _, _ = strings.Cut(s.Track.Artist, ", ")
+	case s.Tags.Artist != "":
+		artist = s.Tags.Artist
+	case isSearchQuery(s.Query):
+		artist = downloader.TitleMetadata(s.Query).Artist
+	}
+	return strings.ToLower(strings.TrimSpace(artist))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// readBatchDir reads every songs file (.txt or Exportify .csv) under dir,
+// in name order. Each song's Batch is its file's path in dir, without the
+// extension.
+func readBatchDir(dir string) ([]song, error) {
+	var songs []song
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if downloader.IsWorkDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".txt" && ext != ".csv" {
+			return nil
+		}
+
+		fileSongs, err := readSongsFromFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for i := range fileSongs {
+			fileSongs[i].Batch = strings.TrimSuffix(rel, filepath.Ext(rel))
+		}
+		songs = append(songs, fileSongs...)
+		files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("no .txt or .csv files in %s", dir)
+	}
+	return songs, nil
+}
+
+// batchTally counts downloads per -batch-dir file
+type batchTally struct {
+	order  []string
+	counts map[string]*batchCount
+}
+
+// batchCount is the outcome of one -batch-dir file
+type batchCount struct {
+	success, failed int
+}
+
+// newBatchTally creates an empty tally
+func newBatchTally() *batchTally {
+	return &batchTally{counts: make(map[string]*batchCount)}
+}
+
+// add counts the outcome of a song of the batch file, if any
+func (t *batchTally) add(batch string, outcome songOutcome) {
+	if batch == "" {
+		return
+	}
+	count, ok := t.counts[batch]
+	if !ok {
+		count = &batchCount{}
+		t.counts[batch] = count
+		t.order = append(t.order, batch)
+	}
+	switch outcome {
+	case songDone:
+		count.success++
+	case songFailed:
+		count.failed++
+	}
+}
+
+// report prints the outcome of each file
+func (t *batchTally) report() {
+	if len(t.order) == 0 {
+		return
+	}
+
+	fmt.Printf("%sPlaylist files:%s\n", colorBold, colorReset)
+	for _, batch := range t.order {
+		count := t.counts[batch]
+		fmt.Printf("  %s: %s%d downloaded%s", batch, colorGreen, count.success, colorReset)
+		if count.failed > 0 {
+			fmt.Printf(", %s%d failed%s", colorRed, count.failed, colorReset)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
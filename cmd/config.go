@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// defaultConfigPath returns where the config file is read from without
+// -config: $XDG_CONFIG_HOME/dj/config.json, else ~/.config/dj/config.json
+func defaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "dj", "config.json")
+	}
+	return expandHome("~/.config/dj/config.json")
+}
+
+// loadConfig reads a config file of default options. It's a JSON object
+// whose keys are flag names, like the options of a project file:
+//
+//	{
+//	  "o": "~/Music/dj",
+//	  "format": "flac",
+//	  "no-live": true,
+//	  "jobs": 4
+//	}
+func loadConfig(path string) (*project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var options map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep numbers as written, e.g. 0.15
+	if err := decoder.Decode(&options); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	p := &project{}
+	for key, value := range options {
+		if key == "config" || key == "project" || flag.Lookup(key) == nil {
+			return nil, fmt.Errorf("%s: unknown option %q", path, key)
+		}
+		switch value.(type) {
+		case string, bool, json.Number:
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, key)
+		}
+		p.Options = append(p.Options, [2]string{key, fmt.Sprint(value)})
+	}
+	// Map order is random, so errors come out the same every run
+	sort.Slice(p.Options, func(i, j int) bool { return p.Options[i][0] < p.Options[j][0] })
+	return p, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// djSoftwares are the -dj-software values, mapped to the key notation each
+// program reads from the TKEY tag
+var djSoftwares = map[string]string{
+	"serato":    "musical",  // Am, F#
+	"rekordbox": "musical",  // Am, F#
+	"traktor":   "open-key", // 1m, 3d
+	"virtualdj": "camelot",  // 8A, 2B
+}
+
+// djTags returns the BPM and key tags of a track, formatted for a DJ program.
+// Either is "" when Spotify has no analysis for the track.
+func djTags(software string, track *spotify.TrackInfo) (bpm, key string) {
+	if track.BPM > 0 {
+		bpm = strconv.Itoa(int(track.BPM + 0.5))
+	}
+
+	switch djSoftwares[software] {
+	case "musical":
+		key = track.Key
+	case "camelot":
+		key = track.Camelot
+	case "open-key":
+		key = camelotToOpenKey(track.Camelot)
+	}
+	return bpm, key
+}
+
+// detectFeatures analyzes a download's tempo and key and fills in what the
+// track is missing, logging to out. For songs not from Spotify it returns a
+// new track named after the video. The track passed in is left unchanged.
+func detectFeatures(ctx context.Context, out io.Writer, dl *downloader.Downloader, result *downloader.DownloadResult, track *spotify.TrackInfo) *spotify.TrackInfo {
+	var detected spotify.TrackInfo
+	if track != nil {
+		detected = *track
+	} else {
+		name := downloader.TitleMetadata(result.Title)
+		if result.Music.Artist != "" && result.Music.Title != "" {
+			name = result.Music
+		}
+		detected = spotify.TrackInfo{Artist: name.Artist, Name: name.Title}
+	}
+
+	analysis, err := dl.Analyze(ctx, result.FilePath)
+	if err != nil {
+		fmt.Fprintf(out, "  %sWarning: BPM detection failed: %v%s\n", colorYellow, err, colorReset)
+		return track
+	}
+	result.Analysis = analysis
+	if detected.BPM <= 0 {
+		detected.BPM = analysis.BPM
+	}
+	if detected.Camelot == "" {
+		detected.SetKey(analysis.Key, analysis.Mode)
+	}
+
+	fmt.Fprintf(out, "  %s♩ Detected %.0f BPM (%.0f%% confidence), %s (%.0f%% confidence)%s\n",
+		colorDim, analysis.BPM, analysis.BPMConfidence*100, keyName(analysis), analysis.KeyConfidence*100, colorReset)
+	return &detected
+}
+
+// keyName returns an analysis' key in musical notation, e.g. Am
+func keyName(analysis *downloader.Analysis) string {
+	var t spotify.TrackInfo
+	t.SetKey(analysis.Key, analysis.Mode)
+	return t.Key
+}
+
+// keySnippet is how much of a YouTube video dj key listens to
+const keySnippet = 2 * time.Minute
+
+// printKey prints a track's BPM, key and Camelot code without downloading
+// it: from Spotify for Spotify tracks that have audio features, otherwise
+// detected from the start of the YouTube audio
+func printKey(ctx context.Context, dl *downloader.Downloader, client *spotify.Client, url string) error {
+	videoURL := url
+	if spotify.IsSpotifyTrackURL(url) {
+		if client == nil {
+			return fmt.Errorf("Spotify credentials required for Spotify URLs")
+		}
+		track, err := client.GetTrack(ctx, spotify.ExtractSpotifyID(url))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s%s - %s%s\n", colorBold, track.Artist, track.Name, colorReset)
+		if track.BPM > 0 && track.Camelot != "" {
+			printKeyFields(track, "Spotify")
+			return nil
+		}
+
+		// Spotify has no analysis for many tracks, so listen to it instead
+		match, err := dl.ResolveWithHint(ctx, track.SearchQuery, song{Track: track}.searchHint())
+		if err != nil {
+			return err
+		}
+		videoURL = match.URL
+	} else if !downloader.IsYouTubeURL(url) {
+		return fmt.Errorf("not a Spotify track or YouTube URL: %s", url)
+	}
+
+	fmt.Printf("%sListening to %s...%s\n", colorDim, videoURL, colorReset)
+	analysis, err := dl.AnalyzeURL(ctx, videoURL, keySnippet)
+	if err != nil {
+		return err
+	}
+	track := &spotify.TrackInfo{BPM: analysis.BPM}
+	track.SetKey(analysis.Key, analysis.Mode)
+	printKeyFields(track, fmt.Sprintf("detected, %.0f%% / %.0f%% confidence", analysis.BPMConfidence*100, analysis.KeyConfidence*100))
+	return nil
+}
+
+// printKeyFields prints a track's BPM, key and Camelot code
+func printKeyFields(track *spotify.TrackInfo, source string) {
+	fmt.Printf("  BPM:      %.0f\n", track.BPM)
+	fmt.Printf("  Key:      %s\n", track.Key)
+	fmt.Printf("  Camelot:  %s\n", track.Camelot)
+	fmt.Printf("  %s(%s)%s\n", colorDim, source, colorReset)
+}
+
+// camelotToOpenKey converts a Camelot code (8A) to Open Key notation (1m),
+// which numbers the same wheel from C major / A minor
+func camelotToOpenKey(camelot string) string {
+	if len(camelot) < 2 {
+		return ""
+	}
+	n, err := strconv.Atoi(camelot[:len(camelot)-1])
+	if err != nil || n < 1 || n > 12 {
+		return ""
+	}
+
+	mode := "m"
+	if strings.HasSuffix(camelot, "B") {
+		mode = "d"
+	}
+	return fmt.Sprintf("%d%s", (n+4)%12+1, mode)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rough assumptions behind batch estimates
+const (
+	estimateLossless   = 900                            // kbps of FLAC and WAV, roughly
+	estimateBest       = 320                            // kbps of -quality best
+	estimateThroughput = 2 << 20                        // Download speed in bytes per second
+	estimateOverhead   = 8 * time.Second                // Search and conversion time per track
+	estimateFallback   = 3*time.Minute + 30*time.Second // Track length when none is known
+)
+
+// batchEstimate is a rough forecast for downloading a batch of songs
+type batchEstimate struct {
+	Tracks   int
+	Format   string
+	Bitrate  int           // kbps
+	Unknown  int           // Tracks without a known duration
+	Average  time.Duration // Duration assumed for the unknown tracks
+	Duration time.Duration
+	Size     int64 // bytes
+	Time     time.Duration
+}
+
+// estimateBatch forecasts total length, size and download time from the
+// songs' Spotify metadata, the audio format and quality and the number of
+// songs downloaded at once. Songs of unknown length count as the average
+// of the known ones.
+func estimateBatch(songs []song, format, quality string, jobs int) batchEstimate {
+	est := batchEstimate{
+		Tracks:  len(songs),
+		Format:  format,
+		Bitrate: estimateBitrate(format, quality),
+	}
+	for _, s := range songs {
+		if s.Track != nil && s.Track.DurationMs > 0 {
+			est.Duration += s.Track.Duration()
+		} else {
+			est.Unknown++
+		}
+	}
+
+	est.Average = estimateFallback
+	if known := est.Tracks - est.Unknown; known > 0 {
+		est.Average = est.Duration / time.Duration(known)
+	}
+	est.Duration += est.Average * time.Duration(est.Unknown)
+
+	est.Size = int64(est.Duration.Seconds() * float64(est.Bitrate) * 1000 / 8)
+	est.Time = estimateOverhead*time.Duration(est.Tracks) +
+		time.Duration(est.Size/estimateThroughput)*time.Second
+	if jobs > 1 {
+		est.Time /= time.Duration(jobs)
+	}
+	return est
+}
+
+// estimateBitrate returns the rough bitrate in kbps of the given -format
+// and -quality
+func estimateBitrate(format, quality string) int {
+	switch {
+	case format == "flac" || format == "wav":
+		return estimateLossless
+	case quality == "best":
+		return estimateBest
+	}
+	if kbps, err := strconv.Atoi(strings.TrimRight(quality, "kK")); err == nil {
+		return kbps
+	}
+	return 192
+}
+
+// printEstimate prints a batch estimate
+func printEstimate(est batchEstimate) {
+	fmt.Printf("%sEstimate:%s\n", colorBold, colorReset)
+	fmt.Printf("  Tracks:    %d", est.Tracks)
+	if est.Unknown > 0 {
+		fmt.Printf(" %s(%d of unknown length, counted as %s)%s", colorDim, est.Unknown, formatDuration(est.Average), colorReset)
+	}
+	fmt.Println()
+	fmt.Printf("  Duration:  %s\n", formatLongDuration(est.Duration))
+	fmt.Printf("  Size:      ~%s (%s %d kbps)\n", formatSize(est.Size), strings.ToUpper(est.Format), est.Bitrate)
+	fmt.Printf("  Time:      ~%s\n", formatLongDuration(est.Time))
+}
+
+// formatLongDuration formats a duration in hours and minutes, e.g. 2h 05m
+func formatLongDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes == 0 {
+		return "<1m"
+	}
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+// formatSize formats a byte count, e.g. 12.3 MB
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	default:
+		return fmt.Sprintf("%d KB", bytes>>10)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+func TestEstimateBitrate(t *testing.T) {
+	tests := []struct {
+		format, quality string
+		want            int
+	}{
+		{"mp3", "192K", 192},
+		{"mp3", "320k", 320},
+		{"opus", "128K", 128},
+		{"m4a", "best", estimateBest},
+		{"flac", "192K", estimateLossless},
+		{"wav", "best", estimateLossless},
+	}
+	for _, tt := range tests {
+		if got := estimateBitrate(tt.format, tt.quality); got != tt.want {
+			t.Errorf("estimateBitrate(%q, %q) = %d, want %d", tt.format, tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateBatch(t *testing.T) {
+	songs := []song{
+		{Track: &spotify.TrackInfo{DurationMs: 200_000}},
+		{Track: &spotify.TrackInfo{DurationMs: 400_000}},
+		{Query: "Daft Punk - Around The World"},
+	}
+
+	est := estimateBatch(songs, "mp3", "320K", 1)
+	if est.Duration != 15*time.Minute || est.Unknown != 1 || est.Average != 5*time.Minute {
+		t.Errorf("estimateBatch() = %s over %d unknown at %s, want 15m0s over 1 unknown at 5m0s", est.Duration, est.Unknown, est.Average)
+	}
+	if want := int64(900 * 320 * 1000 / 8); est.Size != want {
+		t.Errorf("Size = %d, want %d", est.Size, want)
+	}
+
+	if flac := estimateBatch(songs, "flac", "192K", 1); flac.Size <= est.Size {
+		t.Errorf("flac Size = %d, want more than mp3's %d", flac.Size, est.Size)
+	}
+	if parallel := estimateBatch(songs, "mp3", "320K", 3); parallel.Time != est.Time/3 {
+		t.Errorf("Time with 3 jobs = %s, want %s", parallel.Time, est.Time/3)
+	}
+}
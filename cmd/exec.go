@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// runHook runs a -exec command for a finished file through the shell. {} is
+// replaced by the file's path and {name} tokens by template values, all
+// quoted for the shell. The values are also in the environment as DJ_FILE,
+// DJ_ARTIST, DJ_TITLE, DJ_BPM, DJ_KEY and DJ_SOURCE.
+func runHook(ctx context.Context, command, path string, vars map[string]string) error {
+	quoted := map[string]string{"": shellQuote(path)}
+	for name, value := range vars {
+		quoted[name] = shellQuote(value)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", renderTemplate(command, quoted))
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", renderTemplate(command, quoted))
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"DJ_FILE="+path,
+		"DJ_ARTIST="+vars["artist"],
+		"DJ_TITLE="+vars["title"],
+		"DJ_BPM="+vars["bpm"],
+		"DJ_KEY="+vars["key"],
+		"DJ_SOURCE="+vars["source"],
+	)
+	return cmd.Run()
+}
+
+// shellQuote quotes a string as a single shell word
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// exportifyColumns maps the TrackInfo fields read from an Exportify CSV to
+// the column names used by Exportify's versions
+var exportifyColumns = map[string][]string{
+	"uri":      {"Track URI", "Spotify URI", "Spotify ID"},
+	"name":     {"Track Name"},
+	"artist":   {"Artist Name(s)", "Artist Name"},
+	"album":    {"Album Name", "Album"},
+	"release":  {"Album Release Date", "Release Date"},
+	"isrc":     {"ISRC"},
+	"explicit": {"Explicit"},
+	"duration": {"Track Duration (ms)", "Duration (ms)"},
+	"tempo":    {"Tempo"},
+	"key":      {"Key"},
+	"mode":     {"Mode"},
+}
+
+// isExportifyCSV reports whether a songs file is an Exportify CSV export,
+// by its extension or its header line
+func isExportifyCSV(path string, r *bufio.Reader) bool {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return true
+	}
+	header, _ := r.Peek(512)
+	line, _, _ := strings.Cut(string(header), "\n")
+	return strings.Contains(line, "Track Name") && strings.Contains(line, ",")
+}
+
+// readExportifyCSV reads the tracks of an Exportify playlist export, with
+// the Spotify metadata it includes, so no Spotify API access is needed
+func readExportifyCSV(r io.Reader) ([]song, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for field, names := range exportifyColumns {
+		for i, heading := range records[0] {
+			for _, name := range names {
+				if strings.EqualFold(strings.TrimSpace(heading), name) {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("not an Exportify CSV: no Track Name column")
+	}
+
+	var songs []song
+	for lineNo, record := range records[1:] {
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		track := &spotify.TrackInfo{
+			Name:        value("name"),
+			Artist:      exportifyArtists(value("artist")),
+			Album:       value("album"),
+			ISRC:        value("isrc"),
+			ReleaseDate: value("release"),
+		}
+		if track.Name == "" {
+			return nil, fmt.Errorf("line %d: missing track name", lineNo+2)
+		}
+		track.SearchQuery = strings.TrimSpace(track.Artist + " " + track.Name)
+
+		// URIs are spotify:track:ID, older exports have the bare ID
+		if id := value("uri"); id != "" {
+			track.ID = id[strings.LastIndex(id, ":")+1:]
+			track.SpotifyURL = "https://open.spotify.com/track/" + track.ID
+		}
+		track.DurationMs, _ = strconv.Atoi(value("duration"))
+		track.Explicit, _ = strconv.ParseBool(value("explicit"))
+		track.BPM, _ = strconv.ParseFloat(value("tempo"), 64)
+		key, keyErr := strconv.Atoi(value("key"))
+		mode, modeErr := strconv.Atoi(value("mode"))
+		if keyErr == nil && modeErr == nil {
+			track.SetKey(key, mode)
+		}
+
+		songs = append(songs, song{Query: track.SearchQuery, Track: track})
+	}
+	return songs, nil
+}
+
+// exportifyArtists turns Exportify's artist column, names joined by "," with
+// commas inside a name escaped as "\,", into a list joined by ", "
+func exportifyArtists(field string) string {
+	var artists []string
+	var name strings.Builder
+	for i := 0; i < len(field); i++ {
+		switch {
+		case field[i] == '\\' && i+1 < len(field) && field[i+1] == ',':
+			name.WriteByte(',')
+			i++
+		case field[i] == ',':
+			artists = append(artists, strings.TrimSpace(name.String()))
+			name.Reset()
+		default:
+			name.WriteByte(field[i])
+		}
+	}
+	artists = append(artists, strings.TrimSpace(name.String()))
+	return strings.Join(artists, ", ")
+}
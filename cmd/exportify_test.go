@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportifyArtists(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"Daft Punk", "Daft Punk"},
+		{"Daft Punk,Pharrell Williams", "Daft Punk, Pharrell Williams"},
+		{`Tyler\, The Creator,Kali Uchis`, "Tyler, The Creator, Kali Uchis"},
+		{`Earth\, Wind & Fire`, "Earth, Wind & Fire"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := exportifyArtists(tt.field); got != tt.want {
+			t.Errorf("exportifyArtists(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestReadExportifyCSVEscapedArtist(t *testing.T) {
+	csv := "Track URI,Track Name,Artist Name(s)\n" +
+		`spotify:track:abc,See You Again,"Tyler\, The Creator,Kali Uchis"` + "\n"
+	songs, err := readExportifyCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 {
+		t.Fatalf("got %d songs, want 1", len(songs))
+	}
+	track := songs[0].Track
+	if want := "Tyler, The Creator, Kali Uchis"; track.Artist != want {
+		t.Errorf("Artist = %q, want %q", track.Artist, want)
+	}
+	if want := "Tyler, The Creator, Kali Uchis See You Again"; track.SearchQuery != want {
+		t.Errorf("SearchQuery = %q, want %q", track.SearchQuery, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// Failure reasons besides the download error kinds
+const (
+	reasonNoMatch          = "no match"
+	reasonLowMatch         = "low match"
+	reasonNoResults        = "no results"
+	reasonDurationMismatch = "wrong duration"
+	reasonSave             = "could not save"
+	reasonTimeout          = "timed out"
+)
+
+// failureHints suggest a fix for each failure reason
+var failureHints = map[string]string{
+	downloader.KindBotCheck.String():      "try -cookies-from-browser <browser>",
+	downloader.KindAgeRestricted.String(): "sign in with -cookies-from-browser <browser>",
+	downloader.KindSponsorBlock.String():  "try without -sponsorblock",
+	downloader.KindProxy.String():         "check -proxy-list",
+	downloader.KindTransient.String():     "try again later, or with more -retries",
+	downloader.KindGeoBlocked.String():    "try a -proxy in another country",
+	reasonLowMatch:                        "try a lower -fail-on-low-match",
+	reasonNoResults:                       "check the spelling, or search with fewer words",
+	reasonDurationMismatch:                "try a higher -duration-tolerance",
+	reasonTimeout:                         "try a longer -timeout",
+}
+
+// failureSummary counts failed songs by reason, so a big batch failing the
+// same way reads as one line
+type failureSummary struct {
+	counts map[string]int
+	order  []string // Reasons in order of first failure
+}
+
+// newFailureSummary creates an empty summary
+func newFailureSummary() *failureSummary {
+	return &failureSummary{counts: make(map[string]int)}
+}
+
+// add counts a failure
+func (f *failureSummary) add(reason string) {
+	if f.counts[reason] == 0 {
+		f.order = append(f.order, reason)
+	}
+	f.counts[reason]++
+}
+
+// report prints the failures grouped by reason, most common first
+func (f *failureSummary) report() {
+	if len(f.order) == 0 {
+		return
+	}
+
+	reasons := append([]string{}, f.order...)
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return f.counts[reasons[i]] > f.counts[reasons[j]]
+	})
+	for _, reason := range reasons {
+		fmt.Printf("  %s%d failed: %s%s", colorRed, f.counts[reason], reason, colorReset)
+		if hint := failureHints[reason]; hint != "" {
+			fmt.Printf(" %s(%s)%s", colorDim, hint, colorReset)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// failureReason names why a download failed. Anything but a failed yt-dlp
+// download or search means the search found no match.
+func failureReason(err error) string {
+	var dlErr *downloader.DownloadError
+	switch {
+	case errors.As(err, &dlErr):
+		return dlErr.Kind.String()
+	case errors.Is(err, downloader.ErrLowMatch):
+		return reasonLowMatch
+	case errors.Is(err, downloader.ErrNoResults):
+		return reasonNoResults
+	case errors.Is(err, downloader.ErrDurationMismatch):
+		return reasonDurationMismatch
+	}
+	return reasonNoMatch
+}
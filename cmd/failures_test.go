@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w for %q: best was %q", downloader.ErrLowMatch, "query", "title"), reasonLowMatch},
+		{fmt.Errorf("search failed: %w", fmt.Errorf("%w for: query", downloader.ErrNoResults)), reasonNoResults},
+		{fmt.Errorf("%w (3:30 ±15%%) for %q", downloader.ErrDurationMismatch, "query"), reasonDurationMismatch},
+		{&downloader.DownloadError{Kind: downloader.KindBotCheck, Err: errors.New("exit status 1")}, "bot-check"},
+		{context.DeadlineExceeded, reasonNoMatch},
+	}
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
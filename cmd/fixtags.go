@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// fixTags matches each audio file under dir to a Spotify track, by its tags
+// or else its filename, and fills in the artist, title, album, BPM and key
+// tags it's missing. With overwrite, existing tags are replaced too.
+func fixTags(ctx context.Context, dl *downloader.Downloader, client *spotify.Client, dir, software string, overwrite bool) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Files of downloads still in progress
+		if entry.IsDir() && downloader.IsWorkDir(entry.Name()) {
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() && downloader.IsAudioFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no audio files in %s", dir)
+	}
+
+	// Keys in musical notation unless a DJ program asks for another
+	if software == "" {
+		software = "serato"
+	}
+
+	fixed, failed := 0, 0
+	for i, path := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("%s[%d/%d]%s %s\n", colorBlue, i+1, len(files), colorReset, truncate(filepath.Base(path), 55))
+
+		existing, err := dl.ReadTags(ctx, path)
+		if err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			failed++
+			continue
+		}
+
+		query := existing.Artist + " " + existing.Title
+		if existing.Artist == "" || existing.Title == "" {
+			name := downloader.TitleMetadata(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+			query = name.Artist + " " + name.Title
+		}
+		track, err := client.SearchTrack(ctx, strings.TrimSpace(query))
+		if err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			failed++
+			continue
+		}
+		fmt.Printf("  %s→ %s - %s%s\n", colorDim, track.Artist, track.Name, colorReset)
+
+		found := downloader.Metadata{Artist: track.Artist, Title: track.Name, Album: track.Album}
+		found.BPM, found.Key = djTags(software, track)
+		if !overwrite {
+			found = missingTags(existing, found)
+		}
+		if found.IsEmpty() {
+			fmt.Printf("  %s✓ Nothing missing%s\n", colorDim, colorReset)
+			continue
+		}
+
+		if err := dl.Tag(ctx, path, found); err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			failed++
+			continue
+		}
+		fmt.Printf("  %s✓ Tagged%s\n", colorGreen, colorReset)
+		fixed++
+	}
+
+	fmt.Printf("\n%sTagged %d, failed %d, of %d file(s)%s\n", colorBold, fixed, failed, len(files), colorReset)
+	return nil
+}
+
+// missingTags returns the fields of found whose tag is empty in existing
+func missingTags(existing, found downloader.Metadata) downloader.Metadata {
+	var missing downloader.Metadata
+	if existing.Artist == "" {
+		missing.Artist = found.Artist
+	}
+	if existing.Title == "" {
+		missing.Title = found.Title
+	}
+	if existing.Album == "" {
+		missing.Album = found.Album
+	}
+	if existing.BPM == "" {
+		missing.BPM = found.BPM
+	}
+	if existing.Key == "" {
+		missing.Key = found.Key
+	}
+	return missing
+}
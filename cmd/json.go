@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonSchemaVersion is bumped when a field of songJSON changes or goes away
+const jsonSchemaVersion = 1
+
+// songJSON is the -json line written for each song
+type songJSON struct {
+	SchemaVersion int    `json:"schema_version"`
+	Query         string `json:"query"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`
+	Reason        string `json:"reason,omitempty"` // See failureReason
+	FilePath      string `json:"file_path,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Artist        string `json:"artist,omitempty"`
+	Duration      int    `json:"duration,omitempty"` // seconds
+	YouTubeURL    string `json:"youtube_url,omitempty"`
+	BPM           int    `json:"bpm,omitempty"` // From Spotify or -detect-bpm
+	Key           string `json:"key,omitempty"`
+}
+
+// writeSongJSON writes the result of a downloaded or failed song as one
+// line of JSON
+func writeSongJSON(w io.Writer, s song, r songResult) error {
+	line := songJSON{
+		SchemaVersion: jsonSchemaVersion,
+		Query:         s.Query,
+		Success:       r.outcome == songDone,
+	}
+	if r.outcome == songFailed {
+		line.Reason = r.reason
+		if r.err != nil {
+			line.Error = r.err.Error()
+		}
+	}
+	if d := r.download; d != nil {
+		line.FilePath = d.FilePath
+		line.Title = d.Title
+		line.Artist = d.Artist
+		line.Duration = d.Duration
+		line.YouTubeURL = d.YouTubeURL
+		line.BPM = int(d.BPM + 0.5)
+		line.Key = d.Key
+	}
+	return json.NewEncoder(w).Encode(line)
+}
+
+// disableColors blanks the ANSI color codes, for output that isn't a terminal
+func disableColors() {
+	colorReset, colorRed, colorGreen, colorYellow = "", "", "", ""
+	colorBlue, colorCyan, colorDim, colorBold = "", "", "", ""
+}
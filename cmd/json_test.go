@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// readmeJSONFields returns the fields in the README's JSON Output table
+func readmeJSONFields(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile("../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, section, ok := strings.Cut(string(data), "## JSON Output\n")
+	if !ok {
+		t.Fatal("README has no JSON Output section")
+	}
+	section, _, _ = strings.Cut(section, "\n## ")
+
+	// Rows start with the field names, e.g. "| `title`, `artist` | ..."
+	var fields []string
+	for _, line := range strings.Split(section, "\n") {
+		cells := strings.Split(line, "|")
+		if !strings.HasPrefix(line, "| `") || len(cells) < 3 {
+			continue
+		}
+		for _, m := range regexp.MustCompile("`([a-z_]+)`").FindAllStringSubmatch(cells[1], -1) {
+			fields = append(fields, m[1])
+		}
+	}
+	return fields
+}
+
+func TestSongJSONFieldsAreDocumented(t *testing.T) {
+	fields := reflect.TypeOf(songJSON{})
+	var got []string
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ",")
+		got = append(got, name)
+	}
+
+	want := readmeJSONFields(t)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("songJSON fields = %v, README documents %v; update both and bump jsonSchemaVersion", got, want)
+	}
+}
+
+func TestWriteSongJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		result songResult
+		want   map[string]any
+	}{
+		{
+			name: "done",
+			result: songResult{
+				outcome: songDone,
+				download: &downloader.DownloadResult{
+					FilePath:   "/music/Daft Punk - Around The World.mp3",
+					Title:      "Around The World",
+					Artist:     "Daft Punk",
+					Duration:   429,
+					YouTubeURL: "https://www.youtube.com/watch?v=K0HSD_i2DvA",
+					BPM:        120.6,
+					Key:        "Am",
+				},
+			},
+			want: map[string]any{
+				"schema_version": float64(jsonSchemaVersion),
+				"query":          "Daft Punk - Around The World",
+				"success":        true,
+				"file_path":      "/music/Daft Punk - Around The World.mp3",
+				"title":          "Around The World",
+				"artist":         "Daft Punk",
+				"duration":       float64(429),
+				"youtube_url":    "https://www.youtube.com/watch?v=K0HSD_i2DvA",
+				"bpm":            float64(121),
+				"key":            "Am",
+			},
+		},
+		{
+			name: "failed",
+			result: songResult{
+				outcome: songFailed,
+				reason:  reasonNoMatch,
+				err:     errors.New("no video matched"),
+			},
+			want: map[string]any{
+				"schema_version": float64(jsonSchemaVersion),
+				"query":          "Daft Punk - Around The World",
+				"success":        false,
+				"error":          "no video matched",
+				"reason":         "no match",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeSongJSON(&buf, song{Query: "Daft Punk - Around The World"}, tt.result); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+				t.Errorf("output is not one line: %q", buf.String())
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("writeSongJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// logLevel is how much dj prints, set by -quiet and -verbose
+type logLevel int
+
+const (
+	levelQuiet   logLevel = iota // Only failures and the run's summary
+	levelNormal                  // Each song's progress and outcome
+	levelVerbose                 // Also the yt-dlp commands run, their output and the videos matched
+)
+
+// verbosity is the run's log level
+var verbosity = levelNormal
+
+// interactive is whether stdout is a terminal, where progress bars redraw
+var interactive = true
+
+// songLog prints what happens to a song at the run's log level, below the
+// song's header line. Quiet, the header is only printed before a failure.
+type songLog struct {
+	w      io.Writer
+	header string
+	headed bool
+}
+
+// newSongLog creates a log writing to w, printing the header right away
+// unless quiet
+func newSongLog(w io.Writer, header string) *songLog {
+	l := &songLog{w: w, header: header}
+	if verbosity >= levelNormal {
+		l.head()
+	}
+	return l
+}
+
+// head prints the header if it isn't yet
+func (l *songLog) head() {
+	if !l.headed {
+		l.headed = true
+		io.WriteString(l.w, l.header)
+	}
+}
+
+// Write prints like infof, for functions logging to an io.Writer
+func (l *songLog) Write(p []byte) (int, error) {
+	if verbosity < levelNormal {
+		return len(p), nil
+	}
+	l.head()
+	return l.w.Write(p)
+}
+
+// infof prints a step or outcome of the song, unless quiet
+func (l *songLog) infof(format string, args ...any) {
+	fmt.Fprintf(l, format, args...)
+}
+
+// errorf prints a failure of the song, however quiet
+func (l *songLog) errorf(format string, args ...any) {
+	l.head()
+	fmt.Fprintf(l.w, format, args...)
+}
+
+// debugf prints details of the song only when verbose
+func (l *songLog) debugf(format string, args ...any) {
+	if verbosity >= levelVerbose {
+		l.head()
+		fmt.Fprintf(l.w, format, args...)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
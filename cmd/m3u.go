@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// defaultM3UName names the -m3u playlist of songs not from a playlist
+const defaultM3UName = "dj"
+
+// m3uEntry is a downloaded song of an -m3u playlist
+type m3uEntry struct {
+	Playlist string // Name of the playlist file, without extension
+	Path     string
+	Duration int    // seconds, -1 if unknown
+	Title    string // "Artist - Title"
+}
+
+// newM3UEntry describes a downloaded song, from Spotify if it came from
+// there, else from the video. Songs go in their source playlist's or
+// -batch-dir file's playlist.
+func newM3UEntry(s song, r songResult) m3uEntry {
+	e := m3uEntry{Playlist: s.Group, Path: r.file, Duration: -1}
+	if s.Batch != "" {
+		e.Playlist = filepath.Base(s.Batch)
+	}
+	if e.Playlist == "" {
+		e.Playlist = defaultM3UName
+	}
+
+	switch d := r.download; {
+	case s.Track != nil:
+		e.Title = s.Track.Artist + " - " + s.Track.Name
+		if s.Track.DurationMs > 0 {
+			e.Duration = s.Track.DurationMs / 1000
+		}
+	case d != nil && d.Title != "":
+		e.Title = d.Title
+		if d.Music.Artist != "" && d.Music.Title != "" {
+			e.Title = d.Music.Artist + " - " + d.Music.Title
+		}
+		if d.Duration > 0 {
+			e.Duration = d.Duration
+		}
+	default:
+		e.Title = strings.TrimSuffix(filepath.Base(r.file), filepath.Ext(r.file))
+	}
+	return e
+}
+
+// writeM3UPlaylists writes an extended M3U playlist into dir for each
+// playlist of the entries, listing its songs in order of their index, and
+// returns the paths written. Paths in them are relative to dir, so the
+// folder can be moved.
+func writeM3UPlaylists(dir string, entries map[int]m3uEntry) ([]string, error) {
+	indexes := make([]int, 0, len(entries))
+	for i := range entries {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var order []string
+	playlists := make(map[string]*strings.Builder)
+	for _, i := range indexes {
+		e := entries[i]
+		b, ok := playlists[e.Playlist]
+		if !ok {
+			b = &strings.Builder{}
+			b.WriteString("#EXTM3U\n")
+			playlists[e.Playlist] = b
+			order = append(order, e.Playlist)
+		}
+
+		path := e.Path
+		if rel, err := filepath.Rel(dir, e.Path); err == nil {
+			path = rel
+		}
+		fmt.Fprintf(b, "#EXTINF:%d,%s\n%s\n", e.Duration, e.Title, path)
+	}
+
+	var written []string
+	for _, name := range order {
+		path := filepath.Join(dir, downloader.SanitizeFilename(name)+".m3u8")
+		if err := os.WriteFile(path, []byte(playlists[name].String()), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -2,22 +2,32 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/manifest"
 	"github.com/yourusername/dj-bot/internal/spotify"
 )
 
-// ANSI color codes
-const (
+// ANSI color codes, blanked by disableColors
+var (
 	colorReset  = "\033[0m"
 	colorRed    = "\033[31m"
 	colorGreen  = "\033[32m"
@@ -28,15 +38,110 @@ const (
 	colorBold   = "\033[1m"
 )
 
+// audioQualityRegex matches a -quality bitrate, e.g. 320K
+var audioQualityRegex = regexp.MustCompile(`^\d+[kK]$`)
+
+// forceQuitWindow is how soon a second interrupt must follow the first to force quit
+const forceQuitWindow = 3 * time.Second
+
 func main() {
 	// Load .env file silently
 	godotenv.Load()
 
+	// Subcommands come before any flags
+	command := ""
+	if len(os.Args) > 1 && (os.Args[1] == "recent" || os.Args[1] == "liked" || os.Args[1] == "list" || os.Args[1] == "fix-tags" || os.Args[1] == "key" || os.Args[1] == "discover") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	// Define flags
-	outputDir := flag.String("o", ".", "Output directory")
-	inputFile := flag.String("f", "", "Text file with songs (one per line)")
+	outputDir := flag.String("o", defaultOutputDir(), "Output directory")
+	inputFile := flag.String("f", "", "Text file with songs (one per line), or an Exportify CSV")
+	batchDir := flag.String("batch-dir", "", "Download every songs file (.txt or .csv) in this directory, one after another")
+	projectFile := flag.String("project", "", "Load inputs and options from a .dj project file (flags override it)")
+	configPath := flag.String("config", "", "Read default options from this JSON file (default ~/.config/dj/config.json)")
 	spotifyID := flag.String("spotify-id", os.Getenv("SPOTIFY_CLIENT_ID"), "Spotify Client ID")
 	spotifySecret := flag.String("spotify-secret", os.Getenv("SPOTIFY_CLIENT_SECRET"), "Spotify Client Secret")
+	metadataLang := flag.String("metadata-lang", "", "Language for Spotify names, e.g. ja or ja-JP (region also sets the market)")
+	spotifyCreds := flag.String("spotify-creds", "", "JSON/TOML file with Spotify client_id and client_secret")
+	noLive := flag.Bool("no-live", false, "Avoid live versions even when the query says \"live\"")
+	preferLive := flag.Bool("prefer-live", false, "Prefer live versions in search results")
+	discography := flag.Bool("discography", false, "Expand Spotify artist URLs into all their albums and singles instead of their top tracks")
+	splitPlaylists := flag.Bool("split-playlists", false, "Save each Spotify playlist, album or artist, or -batch-dir file, into its own subfolder")
+	playlistIndex := flag.Bool("playlist-index", false, "Write each track's playlist position as its track number")
+	stableNames := flag.Bool("stable-names", false, "Name files by YouTube video ID and skip intact files on re-runs")
+	filenamePrefix := flag.String("filename-prefix", "", "Prefix Spotify tracks' filenames for DJ sorting: bpm, key or bpm-key")
+	replaceSpaces := flag.String("replace-spaces", "", "Replace spaces in filenames with this string, e.g. _")
+	asciiNames := flag.Bool("ascii-names", false, "Transliterate filenames to ASCII (e.g. for CDJs and FAT32 drives)")
+	coverSize := flag.Int("cover-size", 0, "Size of the Spotify album art to embed: closest to this width in px (0 = largest)")
+	youtubeCover := flag.Bool("youtube-cover", false, "Keep the YouTube thumbnail as cover art for Spotify tracks instead of their album art")
+	minMatch := flag.Float64("fail-on-low-match", 0, "Fail songs whose best search result matches less of the query (0..1)")
+	comment := flag.String("comment", "", "Comment tag template, e.g. \"Downloaded by dj on {date} from {source}\" ({date} {source} {artist} {title} {bpm} {key})")
+	searchCount := flag.Int("search-count", 5, "Compare this many YouTube search results to pick the best match")
+	durationTolerance := flag.Float64("duration-tolerance", 0, "Skip results whose length differs from the Spotify track by more than this fraction, e.g. 0.15")
+	mergePath := flag.String("merge", "", "Also join all downloaded tracks, in order, into this MP3 file (for mixes)")
+	crossfade := flag.Duration("crossfade", 0, "Fade between tracks for this long when merging, e.g. 5s (0 = hard cut)")
+	cookies := flag.String("cookies", "", "Netscape cookies.txt file to sign in to YouTube (for age-restricted videos)")
+	cookiesFromBrowser := flag.String("cookies-from-browser", "", "Sign in to YouTube with this browser's cookies, e.g. firefox or chrome")
+	setDate := flag.String("set-date", "", "Set each file's modification time to the video's upload date, the track's release date, or now: upload, release or now")
+	jsonOutput := flag.Bool("json", false, "Print one JSON line per song to stdout for scripts, and everything else to stderr without colors")
+	quiet := flag.Bool("quiet", false, "Print only failures and the summary")
+	verbose := flag.Bool("verbose", false, "Also print the yt-dlp commands run, their output and the videos matched")
+	quietErrors := flag.Bool("quiet-errors", false, "Print only the kind of each failure, e.g. bot-check, instead of yt-dlp's output")
+	proxy := flag.String("proxy", "", "Proxy URL for YouTube and Spotify, e.g. http://proxy:3128 or socks5://127.0.0.1:1080 (default from HTTPS_PROXY/HTTP_PROXY)")
+	proxyList := flag.String("proxy-list", "", "File of proxy URLs to rotate through per download, skipping ones that fail to connect")
+	fetchParallel := flag.Bool("fetch-parallel", false, "Start downloading while Spotify playlists are still being fetched")
+	keepPartial := flag.Bool("keep-partial", false, "Keep partial files of failed or cancelled downloads for inspection")
+	dryRun := flag.Bool("dry-run", false, "Print the songs, the video each would download and its file path, and an estimate of total length, size and time, without downloading")
+	writeM3U := flag.Bool("m3u", false, "Write an .m3u8 playlist of each playlist's downloads, in order, into the output folder")
+	archiveFile := flag.String("archive", "", "Record downloaded video IDs in this yt-dlp download archive, and skip videos recorded there")
+	useManifest := flag.Bool("manifest", false, "Record downloads in .dj-manifest.json in the output folder, and skip songs recorded there on later runs")
+	resumeFile := flag.String("resume-file", "", "When cancelled, write the songs not downloaded yet to this file, to run again with -f")
+	reportUnmatched := flag.String("report-unmatched", "", "Write Spotify tracks with no YouTube match to this file")
+	matchReport := flag.String("match-report", "", "Write a CSV of the searched matches, least confident first, to this file")
+	thumbnailFrame := flag.String("thumbnail-frame", "", "Use the video frame at this time as cover art, e.g. 1:30")
+	ytdlpPath := flag.String("ytdlp-path", os.Getenv("YTDLP_PATH"), "yt-dlp command, e.g. yt-dlp-nightly or \"python3 -m yt_dlp\" (default from PATH)")
+	ffmpegPath := flag.String("ffmpeg-path", os.Getenv("FFMPEG_PATH"), "ffmpeg command (default from PATH)")
+	showVersion := flag.Bool("version", false, "Print the versions of yt-dlp and ffmpeg and exit")
+	update := flag.Bool("update", false, "Update yt-dlp to its latest release and exit")
+	sponsorBlock := flag.Bool("sponsorblock", false, "Cut non-music segments (intros, talking) using SponsorBlock")
+	sponsorBlockCategories := flag.String("sponsorblock-categories", "music_offtopic,intro,outro", "SponsorBlock categories to cut with -sponsorblock")
+	dedup := flag.Bool("dedup", false, "Skip songs that find a YouTube video already downloaded earlier in the run")
+	dedupeAudio := flag.String("dedupe-audio", "", "After the batch, find the same song from different uploads by audio fingerprint (needs fpcalc): report or remove")
+	marketFallback := flag.String("market-fallback", "", "Look up Spotify tracks unavailable in your market in these markets, e.g. US,GB,DE")
+	audioFormat := flag.String("format", "mp3", "Audio format: mp3, opus, flac, m4a or wav")
+	audioQuality := flag.String("quality", "192K", "Audio bitrate, e.g. 128K, 192K or 320K, or best (ignored for flac and wav)")
+	normalize := flag.Bool("normalize", false, "Even out the loudness of downloads to -target-lufs (re-encodes the audio)")
+	targetLUFS := flag.Float64("target-lufs", -14, "Integrated loudness for -normalize, in LUFS, e.g. -14 for streaming or -9 for club play")
+	trimSilence := flag.Bool("trim", false, "Cut silence from the start and end of downloads (re-encodes the audio)")
+	silenceThreshold := flag.Float64("silence-threshold", -50, "Level in dB below which -trim counts audio as silence")
+	id3Version := flag.String("id3-version", "2.3", "ID3 tag version of MP3s: 2.3, 2.4, or 1 to add an ID3v1 tag for old players")
+	tagsFrom := flag.String("tags-from", "", "Write artist, title and album tags from spotify, youtube (music metadata) or title (\"Artist - Title\"); Spotify tracks default to spotify")
+	overwriteTags := flag.Bool("overwrite-tags", false, "With dj fix-tags, replace existing tags instead of only filling in missing ones")
+	detectBPM := flag.Bool("detect-bpm", false, "Detect BPM and key from the audio when Spotify has none, and write them as tags")
+	djSoftware := flag.String("dj-software", "", "Write BPM and key tags in the format of serato, rekordbox, traktor or virtualdj")
+	preview := flag.Bool("preview", false, "Play the first seconds of each match (needs ffplay) and ask before downloading")
+	waveform := flag.Bool("waveform", false, "Save a waveform image next to each file as <name>.waveform.png")
+	waveformSize := flag.String("waveform-size", "1800x140", "Waveform image size, WIDTHxHEIGHT")
+	waveformColor := flag.String("waveform-color", "#1e90ff", "Waveform color, as #RRGGBB or a color name")
+	var hooks stringList
+	flag.Var(&hooks, "exec", "Run this shell command on each downloaded file, with {} as its path and {artist} {title} {bpm} {key} {source} (repeatable)")
+	writeThumbnail := flag.Bool("write-thumbnail", false, "Also save cover art as <name>.jpg next to the audio")
+	songTimeout := flag.Duration("timeout", 0, "Give up on a song whose download takes longer than this, e.g. 5m (0 = no limit)")
+	retries := flag.Int("retries", 3, "Retry downloads failing with network errors this many times, waiting longer each time")
+	jobs := flag.Int("jobs", 1, "Download up to this many songs at once")
+	prefetchCount := flag.Int("prefetch", 2, "Search this many upcoming songs while downloading (0 to disable)")
+	limit := flag.Int("limit", 50, "Max tracks for dj recent (up to 50) and dj discover (up to 100), and dj liked if set")
+	cleanOnly := flag.Bool("clean-only", false, "Skip Spotify tracks marked explicit, and prefer clean versions and radio edits on YouTube")
+	explicitOnly := flag.Bool("explicit-only", false, "Skip Spotify tracks not marked explicit")
+	maxPerArtist := flag.Int("max-per-artist", 0, "Keep at most this many songs per artist, in input order (0 = no limit)")
+	seedTrack := flag.String("seed-track", "", "dj discover: Spotify track URLs to base recommendations on, comma-separated")
+	seedArtist := flag.String("seed-artist", "", "dj discover: Spotify artist URLs to base recommendations on, comma-separated")
+	seedGenre := flag.String("seed-genre", "", "dj discover: genres to base recommendations on, comma-separated, e.g. house,techno")
+	targetBPM := flag.Float64("target-bpm", 0, "dj discover: prefer tracks near this BPM")
+	targetEnergy := flag.Float64("target-energy", 0, "dj discover: prefer tracks near this energy (0..1)")
+	targetKey := flag.String("target-key", "", "dj discover: prefer tracks in this key, e.g. Am or 8A")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `dj - Download music from YouTube
@@ -44,7 +149,14 @@ func main() {
 Usage:
   dj [options] <song>...
   dj [options] -f <file.txt>
+  dj [options] -project <set.dj>
   dj [options] <spotify-playlist-url>
+  dj recent [options]          Download your recently played Spotify tracks
+  dj liked [options]           Download your Spotify Liked Songs
+  dj list [options] <user-url> List a Spotify user's playlists and pick one
+  dj fix-tags [options] <dir>  Fill in missing tags of audio files from Spotify
+  dj key [options] <url>       Print a Spotify or YouTube track's BPM and key
+  dj discover [options]        Download Spotify recommendations for -seed-track, -seed-artist or -seed-genre
 
 Options:
 `)
@@ -63,20 +175,326 @@ Supported inputs:
   - YouTube URLs
   - Spotify track URLs
   - Spotify playlist URLs (downloads all tracks)
+  - Spotify podcast episode and show URLs (searched by show and title)
+  - Spotify IDs with their kind: track:ID, playlist:ID, album:ID, artist:ID, episode:ID, show:ID
+  - YouTube Music playlist and album URLs
   - Text file with songs (one per line)
 
 Environment variables (.env supported):
   SPOTIFY_CLIENT_ID      For Spotify URL support
   SPOTIFY_CLIENT_SECRET  For Spotify URL support
-`)
+  DJ_OUTPUT_DIR          Default output directory (else ~/Music, else .)
+  YTDLP_PATH             yt-dlp command (same as -ytdlp-path)
+  FFMPEG_PATH            ffmpeg command (same as -ffmpeg-path)
+  NO_COLOR               Print without colors (also off when output isn't a terminal)
+
+Config file (-config, default ~/.config/dj/config.json): a JSON object of
+default options by flag name, e.g. {"o": "~/Music/dj", "format": "flac"}
+
+Option precedence: flags > -project file > config file > env
+Spotify credentials precedence: -spotify-id/-spotify-secret > -spotify-creds > env
+
+dj recent and dj liked log in to your Spotify account in the browser on first use.
+Add %s to your app's Redirect URIs in the Spotify dashboard.
+`, spotify.RedirectURL)
 	}
 	flag.Parse()
 
+	// Fill options from the config file, then the project file, keeping the
+	// ones given as flags
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	configFile := *configPath
+	if configFile == "" {
+		configFile = defaultConfigPath()
+	}
+	cfg, err := loadConfig(expandHome(configFile))
+	if err == nil {
+		err = cfg.apply(explicit)
+	} else if *configPath == "" && errors.Is(err, fs.ErrNotExist) {
+		err = nil // The default config file is optional
+	}
+	if err != nil {
+		fmt.Printf("Error: config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	var projectInputs []string
+	if *projectFile != "" {
+		proj, err := loadProject(expandHome(*projectFile))
+		if err == nil {
+			err = proj.apply(explicit)
+		}
+		if err != nil {
+			fmt.Printf("Error: project file: %v\n", err)
+			os.Exit(1)
+		}
+		projectInputs = proj.Inputs
+	}
+
+	// Keep stdout for the -json lines alone
+	var jsonOut io.Writer
+	if *jsonOutput {
+		jsonOut = os.Stdout
+		os.Stdout = os.Stderr
+		disableColors()
+		if *preview {
+			fmt.Println("Error: -preview is interactive and can't be used with -json")
+			os.Exit(1)
+		}
+	}
+
+	if *showVersion {
+		// Nothing is saved, so any existing directory will do
+		dl, err := downloader.NewWithTools(os.TempDir(), downloader.Tools{YtDlp: *ytdlpPath, FFmpeg: *ffmpegPath})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("yt-dlp %s\n", dl.YtDlpVersion)
+		ffmpegVersion := dl.FFmpegVersion
+		if ffmpegVersion == "" {
+			ffmpegVersion = "(unknown version)"
+		}
+		fmt.Printf("ffmpeg %s\n", ffmpegVersion)
+		if err := dl.CheckYtDlpVersion(); err != nil {
+			fmt.Printf("%sWarning: %v%s\n", colorYellow, err, colorReset)
+		}
+		return
+	}
+
+	if *update {
+		// Nothing is saved, so any existing directory will do
+		dl, err := downloader.NewWithTools(os.TempDir(), downloader.Tools{YtDlp: *ytdlpPath, FFmpeg: *ffmpegPath})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		dl.Proxy = *proxy
+		before := dl.YtDlpVersion
+		fmt.Printf("Updating yt-dlp %s...\n", before)
+		if err := dl.UpdateYtDlp(context.Background()); errors.Is(err, downloader.ErrNoSelfUpdate) {
+			fmt.Println("yt-dlp was installed by a package manager, so update it with that, e.g.:")
+			fmt.Println("  brew upgrade yt-dlp        (macOS)")
+			fmt.Println("  sudo apt upgrade yt-dlp    (Ubuntu/Debian)")
+			fmt.Println("  winget upgrade yt-dlp      (Windows)")
+			os.Exit(1)
+		} else if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if dl.YtDlpVersion == before {
+			fmt.Printf("%s✓ yt-dlp %s is up to date%s\n", colorGreen, before, colorReset)
+		} else {
+			fmt.Printf("%s✓ Updated yt-dlp %s → %s%s\n", colorGreen, before, dl.YtDlpVersion, colorReset)
+		}
+		return
+	}
+
+	// Colors and redrawn progress bars are for terminals, not files and pipes
+	interactive = isTerminal(os.Stdout)
+	if !interactive || os.Getenv("NO_COLOR") != "" {
+		disableColors()
+	}
+
+	switch {
+	case *quiet && *verbose:
+		fmt.Println("Error: -quiet and -verbose can't be used together")
+		os.Exit(1)
+	case *quiet:
+		verbosity = levelQuiet
+	case *verbose:
+		verbosity = levelVerbose
+	}
+
+	switch *filenamePrefix {
+	case "", "bpm", "key", "bpm-key":
+	default:
+		fmt.Printf("Error: invalid -filename-prefix %q (use bpm, key or bpm-key)\n", *filenamePrefix)
+		os.Exit(1)
+	}
+	if *filenamePrefix != "" && *stableNames {
+		fmt.Printf("%sWarning: -filename-prefix is ignored with -stable-names%s\n", colorYellow, colorReset)
+		*filenamePrefix = ""
+	}
+
+	if strings.ContainsAny(*replaceSpaces, `/\`) {
+		fmt.Println("Error: -replace-spaces cannot contain path separators")
+		os.Exit(1)
+	}
+
+	if *songTimeout < 0 {
+		fmt.Println("Error: -timeout cannot be negative")
+		os.Exit(1)
+	}
+
+	if *searchCount < 1 || *searchCount > 50 {
+		fmt.Println("Error: -search-count must be between 1 and 50")
+		os.Exit(1)
+	}
+
+	if *durationTolerance < 0 {
+		fmt.Println("Error: -duration-tolerance cannot be negative")
+		os.Exit(1)
+	}
+	if *minMatch < 0 || *minMatch > 1 {
+		fmt.Println("Error: -fail-on-low-match must be between 0 and 1")
+		os.Exit(1)
+	}
+
+	if *mergePath != "" && !strings.EqualFold(filepath.Ext(*mergePath), ".mp3") {
+		fmt.Println("Error: -merge output must be an .mp3 file")
+		os.Exit(1)
+	}
+
+	if *crossfade < 0 {
+		fmt.Println("Error: -crossfade cannot be negative")
+		os.Exit(1)
+	}
+	if *crossfade > 0 && *mergePath == "" {
+		fmt.Printf("%sWarning: -crossfade only applies with -merge%s\n", colorYellow, colorReset)
+	}
+
+	var frameAt time.Duration
+	if *thumbnailFrame != "" {
+		var err error
+		if frameAt, err = parseTimestamp(*thumbnailFrame); err != nil {
+			fmt.Printf("Error: invalid -thumbnail-frame: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var stdin *bufio.Reader
+	if *preview {
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			fmt.Printf("%sWarning: -preview needs an interactive terminal%s\n", colorYellow, colorReset)
+			*preview = false
+		} else {
+			stdin = bufio.NewReader(os.Stdin)
+		}
+	}
+
+	waveformOpts := downloader.WaveformOptions{Color: *waveformColor}
+	if _, err := fmt.Sscanf(*waveformSize, "%dx%d", &waveformOpts.Width, &waveformOpts.Height); err != nil || waveformOpts.Width <= 0 || waveformOpts.Height <= 0 {
+		fmt.Printf("Error: invalid -waveform-size %q (use WIDTHxHEIGHT, e.g. 1800x140)\n", *waveformSize)
+		os.Exit(1)
+	}
+
+	switch *id3Version {
+	case "1", "2.3", "2.4":
+	default:
+		fmt.Printf("Error: invalid -id3-version %q (use 1, 2.3 or 2.4)\n", *id3Version)
+		os.Exit(1)
+	}
+	switch *tagsFrom {
+	case "", "spotify", "youtube", "title":
+	default:
+		fmt.Printf("Error: invalid -tags-from %q (use spotify, youtube or title)\n", *tagsFrom)
+		os.Exit(1)
+	}
+	if _, ok := djSoftwares[*djSoftware]; *djSoftware != "" && !ok {
+		fmt.Printf("Error: invalid -dj-software %q (use serato, rekordbox, traktor or virtualdj)\n", *djSoftware)
+		os.Exit(1)
+	}
+
+	switch *dedupeAudio {
+	case "", "report", "remove":
+	default:
+		fmt.Printf("Error: invalid -dedupe-audio %q (use report or remove)\n", *dedupeAudio)
+		os.Exit(1)
+	}
+
+	var proxyURL *url.URL
+	if *proxy != "" {
+		var err error
+		if proxyURL, err = url.Parse(*proxy); err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			fmt.Printf("Error: invalid -proxy %q (use a URL like http://proxy:3128)\n", *proxy)
+			os.Exit(1)
+		}
+	}
+
+	if *cookies != "" {
+		if _, err := os.Stat(expandHome(*cookies)); err != nil {
+			fmt.Printf("Error: can't read -cookies file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *targetLUFS < -70 || *targetLUFS > -5 {
+		fmt.Println("Error: -target-lufs must be between -70 and -5")
+		os.Exit(1)
+	}
+	if *silenceThreshold >= 0 || *silenceThreshold < -90 {
+		fmt.Println("Error: -silence-threshold must be between -90 and 0 dB")
+		os.Exit(1)
+	}
+
+	if *retries < 0 {
+		fmt.Println("Error: -retries can't be negative")
+		os.Exit(1)
+	}
+	if *jobs < 1 {
+		fmt.Println("Error: -jobs must be at least 1")
+		os.Exit(1)
+	}
+	if *jobs > 1 && *preview {
+		fmt.Println("Error: -preview asks about one song at a time and can't be used with -jobs")
+		os.Exit(1)
+	}
+
+	switch *audioFormat {
+	case "mp3", "opus", "flac", "m4a", "wav":
+	default:
+		fmt.Printf("Error: invalid -format %q (use mp3, opus, flac, m4a or wav)\n", *audioFormat)
+		os.Exit(1)
+	}
+	if *audioQuality != "best" && !audioQualityRegex.MatchString(*audioQuality) {
+		fmt.Printf("Error: invalid -quality %q (use a bitrate like 320K, or best)\n", *audioQuality)
+		os.Exit(1)
+	}
+
+	switch *setDate {
+	case "", "upload", "release", "now":
+	default:
+		fmt.Printf("Error: -set-date must be upload, release or now, got %q\n", *setDate)
+		os.Exit(1)
+	}
+
+	if *cleanOnly && *explicitOnly {
+		fmt.Println("Error: -clean-only and -explicit-only are mutually exclusive")
+		os.Exit(1)
+	}
+
+	if *noLive && *preferLive {
+		fmt.Println("Error: -no-live and -prefer-live are mutually exclusive")
+		os.Exit(1)
+	}
+
+	// Load Spotify credentials file (explicit flags take precedence)
+	if *spotifyCreds != "" {
+		if err := loadCredentialsFile(expandHome(*spotifyCreds), explicit, spotifyID, spotifySecret); err != nil {
+			fmt.Printf("Error: -spotify-creds: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Initialize Spotify client first (needed for playlist expansion)
 	var spotifyClient *spotify.Client
+	var spotifyOpts []spotify.Option
+	if *metadataLang != "" {
+		spotifyOpts = append(spotifyOpts, spotify.WithLanguage(*metadataLang))
+	}
+	if *marketFallback != "" {
+		markets := splitList(strings.ToUpper(*marketFallback))
+		spotifyOpts = append(spotifyOpts, spotify.WithMarketFallback(markets...))
+	}
+	if proxyURL != nil {
+		spotifyOpts = append(spotifyOpts, spotify.WithProxy(proxyURL))
+	}
 	if *spotifyID != "" && *spotifySecret != "" {
 		var err error
-		spotifyClient, err = spotify.New(*spotifyID, *spotifySecret)
+		spotifyClient, err = spotify.New(*spotifyID, *spotifySecret, spotifyOpts...)
 		if err != nil {
 			fmt.Printf("%sWarning: Spotify init failed: %v%s\n", colorYellow, err, colorReset)
 		}
@@ -86,42 +504,95 @@ Environment variables (.env supported):
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Handle interrupt signal
+	// Handle interrupt signal: the first one cancels gracefully, a second
+	// one within forceQuitWindow exits immediately
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		fmt.Println("\nCancelling...")
-		cancel()
+		var lastSignal time.Time
+		for range sigChan {
+			if !lastSignal.IsZero() && time.Since(lastSignal) < forceQuitWindow {
+				fmt.Println("\nForce quitting")
+				exit(130)
+			}
+			lastSignal = time.Now()
+			fmt.Printf("\nCancelling... %sPress Ctrl-C again to force quit%s\n", colorDim, colorReset)
+			cancel()
+		}
 	}()
 
-	// Collect songs from args and/or file
-	var songs []string
+	inputs := flag.Args()
+
+	// List a user's playlists and download the one picked
+	if command == "list" {
+		if flag.NArg() != 1 || !spotify.IsSpotifyUserURL(flag.Arg(0)) {
+			fmt.Println("Usage: dj list [options] <spotify-user-url>")
+			os.Exit(1)
+		}
+		if spotifyClient == nil {
+			fmt.Println("Error: Spotify credentials required for dj list")
+			os.Exit(1)
+		}
 
-	// From arguments (expand Spotify playlists)
-	for _, arg := range flag.Args() {
-		expanded := expandInput(ctx, arg, spotifyClient)
-		songs = append(songs, expanded...)
+		playlists, err := spotifyClient.GetUserPlaylists(ctx, spotify.ExtractSpotifyID(flag.Arg(0)))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		choice := pickPlaylist(playlists)
+		if choice == nil {
+			return
+		}
+		inputs = []string{"https://open.spotify.com/playlist/" + choice.ID}
 	}
 
-	// From file
-	if *inputFile != "" {
-		fileSongs, err := readSongsFromFile(*inputFile)
+	// Look up a single track's BPM and key
+	if command == "key" {
+		if flag.NArg() != 1 {
+			fmt.Println("Usage: dj key [options] <spotify-or-youtube-url>")
+			os.Exit(1)
+		}
+		// Nothing is saved, so any existing directory will do
+		dl, err := downloader.NewWithTools(os.TempDir(), downloader.Tools{YtDlp: *ytdlpPath, FFmpeg: *ffmpegPath})
 		if err != nil {
-			fmt.Printf("Error reading file: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
-		// Expand any Spotify playlists in the file
-		for _, song := range fileSongs {
-			expanded := expandInput(ctx, song, spotifyClient)
-			songs = append(songs, expanded...)
+		dl.CookiesFile = expandHome(*cookies)
+		dl.CookiesFromBrowser = *cookiesFromBrowser
+		dl.Proxy = *proxy
+
+		if err := printKey(ctx, dl, spotifyClient, flag.Arg(0)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	if len(songs) == 0 {
-		fmt.Println("Error: No songs specified")
-		fmt.Println("Use -h for help")
-		os.Exit(1)
+	// Fill in the tags of existing files
+	if command == "fix-tags" {
+		if flag.NArg() != 1 {
+			fmt.Println("Usage: dj fix-tags [options] <dir>")
+			os.Exit(1)
+		}
+		if spotifyClient == nil {
+			fmt.Println("Error: Spotify credentials required for dj fix-tags")
+			os.Exit(1)
+		}
+
+		dir := expandHome(flag.Arg(0))
+		dl, err := downloader.NewWithTools(dir, downloader.Tools{YtDlp: *ytdlpPath, FFmpeg: *ffmpegPath})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		dl.ID3Version = *id3Version
+
+		if err := fixTags(ctx, dl, spotifyClient, dir, *djSoftware, *overwriteTags); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Setup output directory
@@ -136,137 +607,1502 @@ Environment variables (.env supported):
 	}
 
 	// Initialize downloader
-	dl, err := downloader.New(outDir)
+	dl, err := downloader.NewWithTools(outDir, downloader.Tools{YtDlp: *ytdlpPath, FFmpeg: *ffmpegPath})
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		fmt.Println("Make sure yt-dlp and ffmpeg are installed")
+		if errors.Is(err, downloader.ErrToolNotFound) {
+			fmt.Println("Make sure yt-dlp and ffmpeg are installed")
+		}
 		os.Exit(1)
 	}
+	if err := dl.CheckYtDlpVersion(); err != nil {
+		fmt.Printf("%sWarning: %v%s\n", colorYellow, err, colorReset)
+	}
+	// Remove the staging folder of unfinished downloads however dj exits
+	defer dl.Close()
+	onExit(func() { dl.Close() })
 
-	// Print header
-	fmt.Printf("\n%s📁 %s%s\n", colorDim, outDir, colorReset)
-	fmt.Printf("%s🎵 %d song(s)%s\n\n", colorCyan, len(songs), colorReset)
-
-	// Download each song
-	success, failed := 0, 0
+	dl.AudioFormat = *audioFormat
+	dl.AudioQuality = *audioQuality
+	dl.WriteThumbnail = *writeThumbnail
+	dl.MinMatch = *minMatch
+	dl.PreferClean = *cleanOnly
+	dl.DurationTolerance = *durationTolerance
+	dl.SearchCount = *searchCount
+	if verbosity >= levelVerbose {
+		dl.Debug = os.Stderr
+	}
+	if *archiveFile != "" {
+		path, err := createArchive(expandHome(*archiveFile))
+		if err != nil {
+			fmt.Printf("Error: -archive: %v\n", err)
+			exit(1)
+		}
+		dl.ArchiveFile = path
+	}
+	dl.CookiesFile = expandHome(*cookies)
+	dl.CookiesFromBrowser = *cookiesFromBrowser
+	dl.Proxy = *proxy
+	dl.KeepPartial = *keepPartial
+	dl.MaxRetries = *retries
+	dl.NormalizeLoudness = *normalize
+	dl.TargetLUFS = *targetLUFS
+	dl.TrimSilence = *trimSilence
+	dl.SilenceThresholdDB = *silenceThreshold
+	if *proxyList != "" {
+		proxies, err := readProxyList(expandHome(*proxyList))
+		if err != nil {
+			fmt.Printf("Error reading proxy list: %v\n", err)
+			exit(1)
+		}
+		dl.Proxies = downloader.NewProxyPool(proxies)
+	}
+	if *sponsorBlock {
+		dl.SponsorBlock = *sponsorBlockCategories
+	}
+	dl.StableNames = *stableNames
+	dl.ASCIINames = *asciiNames
+	dl.SpaceReplacement = *replaceSpaces
+	dl.ID3Version = *id3Version
+	switch {
+	case *noLive:
+		dl.LiveMode = downloader.LiveAvoid
+	case *preferLive:
+		dl.LiveMode = downloader.LivePrefer
+	}
 
-	for i, song := range songs {
-		select {
-		case <-ctx.Done():
-			fmt.Println("Cancelled")
-			os.Exit(1)
-		default:
+	// Collect songs from args and/or file. With -fetch-parallel this runs
+	// alongside the downloads, so they start before big playlists are fetched.
+	queue := newSongQueue()
+	artistCap := newArtistLimit(*maxPerArtist)
+	filtered := 0 // Songs left out by -clean-only or -explicit-only
+	queue.keep = func(s song) bool {
+		if s.Track != nil && ((*cleanOnly && s.Track.Explicit) || (*explicitOnly && !s.Track.Explicit)) {
+			filtered++
+			return false
 		}
+		return *maxPerArtist <= 0 || artistCap.allow(s)
+	}
+	collectSongs := func() {
+		defer queue.close()
 
-		fmt.Printf("%s[%d/%d]%s %s\n", colorBlue, i+1, len(songs), colorReset, truncate(song, 55))
+		// From the user's Spotify account
+		if command == "recent" {
+			userClient, err := spotify.NewWithUserAuth(ctx, *spotifyID, *spotifySecret, spotifyOpts...)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			tracks, err := userClient.RecentlyPlayed(ctx, *limit)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			fmt.Printf("%s🕘 Recently played: %d tracks%s\n", colorCyan, len(tracks), colorReset)
+			for i := range tracks {
+				queue.add(song{Query: tracks[i].SearchQuery, Track: &tracks[i]})
+			}
+		}
+		if command == "liked" {
+			userClient, err := spotify.NewWithUserAuth(ctx, *spotifyID, *spotifySecret, spotifyOpts...)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			likedLimit := 0
+			if explicit["limit"] {
+				likedLimit = *limit
+			}
+			tracks, err := userClient.GetSavedTracks(ctx, likedLimit)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			fmt.Printf("%s💚 Liked Songs: %d tracks%s\n", colorCyan, len(tracks), colorReset)
+			for i := range tracks {
+				queue.add(song{Query: tracks[i].SearchQuery, Track: &tracks[i]})
+			}
+		}
 
-		// Resolve Spotify track URL to search query
-		query := song
-		if spotify.IsSpotifyTrackURL(song) && spotifyClient != nil {
-			if info, err := spotifyClient.GetTrack(ctx, spotify.ExtractSpotifyID(song)); err == nil {
-				query = info.SearchQuery
-				fmt.Printf("  %s→ %s - %s", colorDim, info.Artist, info.Name)
-				if info.BPM > 0 {
-					fmt.Printf(" [%.0f BPM, %s]", info.BPM, info.Key)
-				}
-				fmt.Printf("%s\n", colorReset)
+		// From Spotify recommendations
+		if command == "discover" {
+			if spotifyClient == nil {
+				fmt.Println("Error: Spotify credentials required for dj discover")
+				exit(1)
+			}
+			tracks, err := spotifyClient.GetRecommendations(ctx, spotify.Recommendation{
+				SeedTracks:   spotifyIDs(*seedTrack),
+				SeedArtists:  spotifyIDs(*seedArtist),
+				SeedGenres:   splitList(*seedGenre),
+				TargetBPM:    *targetBPM,
+				TargetEnergy: *targetEnergy,
+				TargetKey:    *targetKey,
+				Limit:        *limit,
+			})
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			fmt.Printf("%s🔎 Recommended: %d tracks%s\n", colorCyan, len(tracks), colorReset)
+			for i := range tracks {
+				queue.add(song{Query: tracks[i].SearchQuery, Track: &tracks[i]})
 			}
 		}
 
-		// Download
-		result, err := download(ctx, dl, query)
-		if err != nil {
-			fmt.Printf("  %s✗ %v%s\n\n", colorRed, err, colorReset)
-			failed++
-			continue
+		// From the project file and arguments
+		var items []song
+		for _, arg := range append(projectInputs, inputs...) {
+			items = append(items, song{Query: arg})
 		}
 
-		fmt.Printf("  %s✓ %s%s\n\n", colorGreen, filepath.Base(result.FilePath), colorReset)
-		success++
-	}
+		// From file
+		if *inputFile != "" {
+			fileSongs, err := readSongsFromFile(*inputFile)
+			if err != nil {
+				fmt.Printf("Error reading file: %v\n", err)
+				exit(1)
+			}
+			items = append(items, fileSongs...)
+		}
 
-	// Summary
-	if failed > 0 {
-		fmt.Printf("%sDone: %s%d downloaded%s, %s%d failed%s\n", colorBold, colorGreen, success, colorReset+colorBold, colorRed, failed, colorReset)
-		os.Exit(1)
-	} else {
-		fmt.Printf("%sDone: %s%d downloaded%s\n", colorBold, colorGreen, success, colorReset)
-	}
-}
+		// From every file of a directory
+		if *batchDir != "" {
+			batchSongs, err := readBatchDir(expandHome(*batchDir))
+			if err != nil {
+				fmt.Printf("Error reading batch directory: %v\n", err)
+				exit(1)
+			}
+			items = append(items, batchSongs...)
+		}
 
-// expandInput expands a single input into one or more songs
-// Handles Spotify playlists by fetching all tracks
-func expandInput(ctx context.Context, input string, spotifyClient *spotify.Client) []string {
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return nil
+		// Expand any Spotify playlists
+		expandInputs(ctx, items, spotifyClient, dl, queue, *discography)
 	}
 
-	// Check if it's a Spotify playlist URL
-	if spotify.IsSpotifyPlaylistURL(input) {
-		if spotifyClient == nil {
-			fmt.Printf("%sWarning: Spotify credentials required for playlist: %s%s\n", colorYellow, truncate(input, 50), colorReset)
-			return nil
+	if *fetchParallel {
+		go collectSongs()
+	} else {
+		collectSongs()
+		if queue.total() == 0 {
+			fmt.Println("Error: No songs specified")
+			fmt.Println("Use -h for help")
+			exit(1)
 		}
+	}
 
-		playlistID := spotify.ExtractSpotifyID(input)
-		if playlistID == "" {
-			fmt.Printf("%sWarning: Could not extract playlist ID from: %s%s\n", colorYellow, truncate(input, 50), colorReset)
-			return nil
+	// Show what each song would download, and estimate the batch from
+	// Spotify metadata
+	if *dryRun {
+		var songs []song
+		for i := 0; ; i++ {
+			s, ok := queue.get(i)
+			if !ok {
+				break
+			}
+			if spotify.IsSpotifyTrackURL(s.Query) && spotifyClient != nil {
+				if info, err := spotifyClient.GetTrack(ctx, spotify.ExtractSpotifyID(s.Query)); err == nil {
+					s.Track = info
+				}
+			}
+			songs = append(songs, s)
 		}
-
-		fmt.Printf("%s📋 Fetching Spotify playlist...%s\n", colorDim, colorReset)
-		playlist, err := spotifyClient.GetPlaylist(ctx, playlistID)
-		if err != nil {
-			fmt.Printf("%sWarning: Failed to fetch playlist: %v%s\n", colorYellow, err, colorReset)
-			return nil
+		if len(songs) == 0 {
+			fmt.Println("Error: No songs specified")
+			exit(1)
 		}
 
-		fmt.Printf("%s📋 Playlist: %s%s%s (%d tracks)\n\n", colorCyan, colorBold, playlist.Name, colorReset, len(playlist.Tracks))
+		fmt.Printf("%sDry run: nothing will be downloaded%s\n\n", colorDim, colorReset)
+		for i, s := range songs {
+			fmt.Printf("%s[%d/%d]%s %s", colorBlue, i+1, len(songs), colorReset, truncate(s.Query, 55))
+			if s.Track != nil && s.Track.DurationMs > 0 {
+				fmt.Printf(" %s(%s)%s", colorDim, formatDuration(s.Track.Duration()), colorReset)
+			}
+			fmt.Println()
 
-		// Convert tracks to search queries
-		var songs []string
-		for _, track := range playlist.Tracks {
-			songs = append(songs, track.SearchQuery)
+			dir := ""
+			folder := s.Group
+			if s.Batch != "" {
+				folder = s.Batch
+			}
+			if *splitPlaylists && folder != "" {
+				dir = filepath.Join(outDir, downloader.SanitizeFilename(folder))
+			}
+			printPlan(ctx, dl, s, *filenamePrefix, dir)
+			fmt.Println()
 		}
-		return songs
+		reportFiltered(filtered, *cleanOnly)
+		artistCap.report()
+		printEstimate(estimateBatch(songs, *audioFormat, *audioQuality, *jobs))
+		return
 	}
 
-	// Not a playlist, return as-is
-	return []string{input}
-}
-
-// readSongsFromFile reads songs from a text file
-func readSongsFromFile(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	// Download history of the output folder, saved however dj exits
+	var history *manifest.Manifest
+	if *useManifest {
+		var err error
+		if history, err = manifest.Load(outDir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		onExit(func() { history.Save() })
 	}
-	defer file.Close()
 
-	var songs []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	// Print header
+	if verbosity >= levelNormal {
+		fmt.Printf("\n%s📁 %s%s\n", colorDim, outDir, colorReset)
+		if *fetchParallel {
+			fmt.Printf("%s🎵 Fetching songs while downloading%s\n\n", colorCyan, colorReset)
+		} else {
+			fmt.Printf("%s🎵 %d song(s)%s\n\n", colorCyan, queue.total(), colorReset)
 		}
-		songs = append(songs, line)
 	}
-	return songs, scanner.Err()
-}
 
-// download downloads a song with progress bar
-func download(ctx context.Context, dl *downloader.Downloader, query string) (*downloader.DownloadResult, error) {
-	var lastPct float64
-	barWidth := 30
+	// Downloaders for per-playlist subfolders
+	groupDLs := make(map[string]*downloader.Downloader)
+	var groupMu sync.Mutex
 
-	progress := func(pct float64, status string) {
-		if pct < lastPct {
-			return // Don't go backwards
-		}
-		lastPct = pct
+	// Results of the downloads, guarded by resultsMu with -jobs
+	var resultsMu sync.Mutex
+	success, failed := 0, 0
+	files := make(map[int]string)        // Files of this run by song index
+	m3uEntries := make(map[int]m3uEntry) // Songs of the -m3u playlists by index
+	finished := make(map[int]bool)       // Songs that need no resuming
+	var unmatched []unmatchedTrack
+	var matches []matchedSong
+	failures := newFailureSummary()
+	batches := newBatchTally()
+
+	// Song index of each YouTube video being or already downloaded, for -dedup
+	videos := make(map[string]int)
+	var videosMu sync.Mutex
+
+	// record adds up the result of song i
+	record := func(i int, s song, r songResult) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		switch r.outcome {
+		case songDone:
+			success++
+			files[i] = r.file
+			if *writeM3U {
+				m3uEntries[i] = newM3UEntry(s, r)
+			}
+		case songFailed:
+			failed++
+			failures.add(r.reason)
+		}
+		batches.add(s.Batch, r.outcome)
+		if r.match != nil {
+			matches = append(matches, *r.match)
+		}
+		if r.unmatched != nil {
+			unmatched = append(unmatched, *r.unmatched)
+		}
+		if !r.interrupted && r.outcome != songStop {
+			finished[i] = true
+		}
+		if jsonOut != nil && (r.outcome == songDone || r.outcome == songFailed) {
+			if err := writeSongJSON(jsonOut, s, r); err != nil {
+				fmt.Printf("  %sWarning: could not write JSON: %v%s\n", colorYellow, err, colorReset)
+			}
+		}
+	}
+
+	// The -jobs status line, nil for one song at a time
+	var status *statusLine
+
+	// processSong downloads, tags and places song i, logging to out
+	processSong := func(i int, s song, out io.Writer, prefetched *prefetchResult) songResult {
+		log := newSongLog(out, fmt.Sprintf("%s[%d/%d]%s %s\n", colorBlue, i+1, queue.total(), colorReset, truncate(s.Query, 55)))
+
+		// Resolve Spotify track URL to search query
+		query := s.Query
+		if spotify.IsSpotifyTrackURL(s.Query) && spotifyClient != nil {
+			if info, err := spotifyClient.GetTrack(ctx, spotify.ExtractSpotifyID(s.Query)); err == nil {
+				s.Track = info
+				query = info.SearchQuery
+				log.infof("  %s→ %s - %s", colorDim, info.Artist, info.Name)
+				if info.DurationMs > 0 {
+					log.infof(" (%s)", formatDuration(info.Duration()))
+				}
+				if info.BPM > 0 {
+					log.infof(" [%.0f BPM, %s]", info.BPM, info.Key)
+				}
+				log.infof("%s\n", colorReset)
+			}
+		}
+
+		// Skip songs an earlier run downloaded, wherever they were in the
+		// playlist then
+		manifestKey := ""
+		if history != nil {
+			spotifyID := ""
+			if s.Track != nil {
+				spotifyID = s.Track.ID
+			}
+			manifestKey = manifest.Key(spotifyID, s.Query)
+			if path, ok := history.Lookup(manifestKey); ok {
+				log.infof("  %s✓ %s (in manifest)%s\n\n", colorDim, filepath.Base(path), colorReset)
+				return songResult{outcome: songDone, file: path, download: &downloader.DownloadResult{FilePath: path}}
+			}
+		}
+		remember := func(result *downloader.DownloadResult) {
+			if history == nil {
+				return
+			}
+			entry := manifest.Entry{Query: s.Query, VideoID: downloader.ExtractYouTubeID(result.YouTubeURL)}
+			if s.Track != nil {
+				entry.SpotifyID = s.Track.ID
+			}
+			history.Add(manifestKey, entry, result.FilePath)
+		}
+
+		// Route playlist tracks into their own subfolder, or each
+		// -batch-dir file's songs into one for the file
+		songDL := dl
+		folder := s.Group
+		if s.Batch != "" {
+			folder = s.Batch
+		}
+		if *splitPlaylists && folder != "" {
+			groupMu.Lock()
+			groupDL, err := groupDownloader(dl, groupDLs, outDir, folder)
+			groupMu.Unlock()
+			if err != nil {
+				log.errorf("  %s✗ %v%s\n\n", colorRed, err, colorReset)
+				return songResult{outcome: songFailed, reason: reasonSave, err: err}
+			}
+			songDL = groupDL
+		}
+
+		// Search before downloading, so the video is known up front for
+		// -preview, -dedup and finding a file already downloaded
+		if prefetched == nil && !downloader.IsYouTubeURL(query) {
+			candidate, err := songDL.ResolveWithHint(ctx, query, s.searchHint())
+			prefetched = &prefetchResult{candidate, err}
+		}
+
+		// Skip videos another song of the run already got. A failed search
+		// is reported by the download below.
+		videoID := ""
+		if *dedup && (prefetched == nil || prefetched.err == nil) {
+			url := query
+			if prefetched != nil {
+				url = prefetched.candidate.URL
+			}
+			if id := downloader.ExtractYouTubeID(url); id != "" {
+				videosMu.Lock()
+				first, seen := videos[id]
+				if !seen {
+					videos[id] = i
+				}
+				videosMu.Unlock()
+				if seen {
+					log.infof("  %sDuplicate of track %d, skipped%s\n\n", colorDim, first+1, colorReset)
+					return songResult{outcome: songSkipped}
+				}
+				videoID = id
+			}
+		}
+
+		// Audition the match before committing to it
+		if *preview {
+			if prefetched == nil || prefetched.err == nil {
+				url := query
+				if prefetched != nil {
+					url = prefetched.candidate.URL
+					log.infof("  %s▶ %s%s\n", colorDim, prefetched.candidate.Title, colorReset)
+				}
+				switch askPreview(ctx, songDL, stdin, url) {
+				case "n":
+					log.infof("  %sSkipped%s\n\n", colorDim, colorReset)
+					return songResult{outcome: songSkipped}
+				case "s":
+					log.infof("  %sSkipping the rest%s\n\n", colorDim, colorReset)
+					return songResult{outcome: songStop}
+				}
+			}
+		}
+
+		// Leave a file already in the output folder alone rather than
+		// download it again. -stable-names checks its own by checksum, and
+		// a name from detected BPM or key isn't known until downloaded.
+		if prefetched != nil && prefetched.err == nil && !songDL.StableNames {
+			name := prefixedName(*filenamePrefix, s.Track)
+			if name != "" || *filenamePrefix == "" || !*detectBPM {
+				path := songDL.PlannedPath(prefetched.candidate, name)
+				if _, err := os.Stat(path); err == nil {
+					log.infof("  %s✓ %s (already exists)%s\n\n", colorDim, filepath.Base(path), colorReset)
+					existing := &downloader.DownloadResult{FilePath: path, YouTubeURL: prefetched.candidate.URL, Skipped: true}
+					remember(existing)
+					return songResult{outcome: songDone, file: path, download: existing}
+				}
+			}
+		}
+
+		// Download, giving up on a stuck one (e.g. a livestream) after
+		// -timeout without stopping the run
+		dlCtx, cancelSong := ctx, func() {}
+		if *songTimeout > 0 {
+			dlCtx, cancelSong = context.WithTimeout(ctx, *songTimeout)
+		}
+		var progress downloader.ProgressCallback
+		if status != nil {
+			progress = status.progress(i)
+		} else if *jobs <= 1 && !*jsonOutput && verbosity >= levelNormal {
+			progress = progressBar()
+		}
+		result, err := download(dlCtx, songDL, query, s.searchHint(), prefetched, progress)
+		timedOut := err != nil && ctx.Err() == nil && dlCtx.Err() != nil
+		cancelSong()
+		if errors.Is(err, downloader.ErrArchived) {
+			log.infof("  %s✓ Already in %s%s\n\n", colorDim, filepath.Base(*archiveFile), colorReset)
+			return songResult{outcome: songSkipped}
+		}
+		var dlErr *downloader.DownloadError
+		if errors.As(err, &dlErr) {
+			for _, path := range dlErr.PartialFiles {
+				log.infof("  %sKept partial file: %s%s\n", colorDim, path, colorReset)
+			}
+		}
+		if timedOut {
+			err, dlErr = fmt.Errorf("timed out after %s", *songTimeout), nil
+		}
+		if err != nil {
+			// Let a later duplicate try the video again
+			if videoID != "" {
+				videosMu.Lock()
+				delete(videos, videoID)
+				videosMu.Unlock()
+			}
+			failure := songResult{outcome: songFailed, reason: failureReason(err), err: err, interrupted: ctx.Err() != nil}
+			if timedOut {
+				failure.reason = reasonTimeout
+			}
+			// Anything but a failed download means the search found no match
+			if s.Track != nil && dlErr == nil && ctx.Err() == nil && !timedOut {
+				failure.unmatched = &unmatchedTrack{Track: s.Track, Reason: err.Error()}
+			}
+			switch {
+			case *quietErrors:
+				log.errorf("  %s✗ Failed: %s%s\n\n", colorRed, failure.reason, colorReset)
+			case errors.Is(err, downloader.ErrGeoBlocked) && *proxy == "":
+				log.errorf("  %s✗ Not available in your country: try a -proxy in another one%s\n\n", colorRed, colorReset)
+			case downloader.IsAgeRestricted(err) && *cookies == "" && *cookiesFromBrowser == "":
+				log.errorf("  %s✗ Age-restricted video: sign in with -cookies-from-browser <browser> or -cookies <cookies.txt>%s\n\n", colorRed, colorReset)
+			case dlErr != nil && dlErr.Kind == downloader.KindSponsorBlock:
+				log.errorf("  %s✗ SponsorBlock failed, try again without -sponsorblock: %v%s\n\n", colorRed, err, colorReset)
+			default:
+				log.errorf("  %s✗ %v%s\n\n", colorRed, err, colorReset)
+			}
+			return failure
+		}
+
+		var match *matchedSong
+		if result.Match != nil {
+			match = &matchedSong{Query: query, Match: result.Match}
+			log.debugf("  %s▶ %s (%s, score %.2f)%s\n", colorDim, result.Match.Title, result.YouTubeURL, result.Match.Score, colorReset)
+		}
+
+		// A file already downloaded is left as it is, since changing it
+		// would break its checksum
+		if result.Skipped {
+			log.infof("  %s✓ %s (already downloaded)%s\n\n", colorDim, filepath.Base(result.FilePath), colorReset)
+			remember(result)
+			return songResult{outcome: songDone, file: result.FilePath, download: result, match: match}
+		}
+
+		// BPM and key for tags and filenames, detected locally where
+		// Spotify has none
+		features := s.Track
+		if *detectBPM && (features == nil || features.BPM <= 0 || features.Camelot == "") {
+			features = detectFeatures(ctx, log, songDL, result, features)
+		}
+
+		// Apply tag overrides from the input file and the tag options.
+		// Spotify tracks are tagged from Spotify unless -tags-from says
+		// otherwise, rather than with what yt-dlp guessed from the video.
+		source := *tagsFrom
+		if source == "" && s.Track != nil {
+			source = "spotify"
+		}
+		tags := sourceTags(source, result, s.Track)
+		mergeTags(&tags, s.Tags)
+		if *playlistIndex && s.Index > 0 {
+			tags.Track = s.Index
+		}
+		if *comment != "" {
+			tags.Comment = renderTemplate(*comment, templateVars(result, features))
+		}
+		if features != nil {
+			result.BPM, result.Key = features.BPM, features.Key
+			software := *djSoftware
+			if software == "" {
+				software = "serato" // Musical key notation
+			}
+			tags.BPM, tags.Key = djTags(software, features)
+		}
+		if !tags.IsEmpty() {
+			if err := songDL.Tag(ctx, result.FilePath, tags); err != nil {
+				log.infof("  %sWarning: tagging failed: %v%s\n", colorYellow, err, colorReset)
+			}
+		}
+
+		// Replace the YouTube thumbnail with Spotify album art
+		if frameAt > 0 {
+			if err := songDL.EmbedFrame(ctx, result.FilePath, result.YouTubeURL, frameAt); err != nil {
+				log.infof("  %sWarning: keeping original cover art: %v%s\n", colorYellow, err, colorReset)
+			}
+		} else if !*youtubeCover && s.Track != nil {
+			coverURL := s.Track.AlbumArtURL
+			if *coverSize > 0 {
+				coverURL = s.Track.CoverURL(*coverSize)
+			}
+			if coverURL != "" {
+				if err := songDL.EmbedCover(ctx, result.FilePath, coverURL); err != nil {
+					log.infof("  %sWarning: cover art failed: %v%s\n", colorYellow, err, colorReset)
+				}
+			}
+		}
+
+		// Prefix the filename with BPM/key for DJ sorting
+		if name := prefixedName(*filenamePrefix, features); name != "" {
+			if err := songDL.Rename(result, name); err != nil {
+				log.infof("  %sWarning: rename failed: %v%s\n", colorYellow, err, colorReset)
+			}
+		}
+
+		// Only now, tagged and named, does the file appear in the output folder
+		// Another run may have saved a file of the same name meanwhile
+		if err := songDL.Place(result); errors.Is(err, downloader.ErrExists) {
+			log.infof("  %s✓ %s (already exists)%s\n\n", colorDim, filepath.Base(result.FilePath), colorReset)
+			remember(result)
+			return songResult{outcome: songDone, file: result.FilePath, download: result, match: match}
+		} else if err != nil {
+			songDL.Discard(result)
+			log.errorf("  %s✗ %v%s\n\n", colorRed, err, colorReset)
+			return songResult{outcome: songFailed, reason: reasonSave, err: err, download: result, match: match}
+		}
+
+		// Date the file for sorting by release chronology
+		if *setDate != "" {
+			if date, ok := fileDate(*setDate, result, s.Track); ok {
+				if err := os.Chtimes(result.FilePath, date, date); err != nil {
+					log.infof("  %sWarning: could not set file date: %v%s\n", colorYellow, err, colorReset)
+				}
+			} else {
+				log.infof("  %sWarning: no %s date to set%s\n", colorYellow, *setDate, colorReset)
+			}
+		}
+
+		log.infof("  %s✓ %s%s\n", colorGreen, filepath.Base(result.FilePath), colorReset)
+		if result.ResumeDiscarded {
+			log.infof("  %sResumed file was broken, downloaded again from scratch%s\n", colorYellow, colorReset)
+		}
+		if result.Trimmed > 0 {
+			log.infof("  %s✂  Cut %s of non-music segments%s\n", colorDim, formatDuration(time.Duration(result.Trimmed)*time.Second), colorReset)
+		}
+		if result.ThumbnailPath != "" {
+			log.infof("  %s🖼  %s%s\n", colorDim, filepath.Base(result.ThumbnailPath), colorReset)
+		}
+
+		// Draw the waveform last, so it's named after the final file
+		if *waveform {
+			if pngPath, err := songDL.GenerateWaveform(ctx, result.FilePath, waveformOpts); err != nil {
+				log.infof("  %sWarning: waveform failed: %v%s\n", colorYellow, err, colorReset)
+			} else {
+				log.infof("  %s〰  %s%s\n", colorDim, filepath.Base(pngPath), colorReset)
+			}
+		}
+
+		// Hand the finished file to the user's commands
+		for _, hook := range hooks {
+			if err := runHook(ctx, hook, result.FilePath, templateVars(result, features)); err != nil {
+				log.infof("  %sWarning: -exec %q failed: %v%s\n", colorYellow, truncate(hook, 40), err, colorReset)
+			}
+		}
+		log.infof("\n")
+		remember(result)
+		return songResult{outcome: songDone, file: result.FilePath, download: result, match: match}
+	}
+
+	if *jobs <= 1 {
+		// Search upcoming songs in the background
+		prefetch := newPrefetcher(ctx, dl, queue, *prefetchCount)
+
+		for i := 0; ctx.Err() == nil; i++ {
+			s, ok := queue.get(i)
+			if !ok {
+				break
+			}
+
+			// Start searching for the next songs while this one downloads
+			prefetch.prefetch(i)
+			var prefetched *prefetchResult
+			if res, ok := prefetch.take(i); ok {
+				prefetched = &res
+			}
+
+			r := processSong(i, s, os.Stdout, prefetched)
+			record(i, s, r)
+			if r.outcome == songStop {
+				break
+			}
+		}
+	} else {
+		// Each song's log is printed in one piece once it's done, as
+		// progress bars of parallel downloads would garble each other.
+		// Instead, one status line sums up the songs in progress.
+		status = newStatusLine(os.Stderr, queue.total)
+		status.hidden = verbosity < levelNormal
+		statusCtx, stopStatus := context.WithCancel(ctx)
+		statusDone := make(chan struct{})
+		go func() {
+			status.run(statusCtx)
+			close(statusDone)
+		}()
+
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for range *jobs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					s, _ := queue.peek(i)
+					status.start(i)
+					if verbosity >= levelNormal {
+						status.print([]byte(fmt.Sprintf("%s[%d/%d] Started %s%s\n", colorDim, i+1, queue.total(), truncate(s.Query, 55), colorReset)))
+					}
+
+					var out bytes.Buffer
+					r := processSong(i, s, &out, nil)
+					record(i, s, r)
+					status.finish(i, r.outcome == songFailed)
+					status.print(out.Bytes())
+				}
+			}()
+		}
+
+	feed:
+		for i := 0; ; i++ {
+			if _, ok := queue.get(i); !ok {
+				break
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(indexes)
+		wg.Wait()
+		stopStatus()
+		<-statusDone
+	}
+
+	if history != nil {
+		if err := history.Save(); err != nil {
+			fmt.Printf("%sWarning: could not save manifest: %v%s\n", colorYellow, err, colorReset)
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Println("Cancelled")
+		// Leave the songs not downloaded yet in -resume-file
+		if *resumeFile != "" {
+			if n, err := writeResumeFile(expandHome(*resumeFile), queue, finished); err != nil {
+				fmt.Printf("%sWarning: could not write resume file: %v%s\n", colorYellow, err, colorReset)
+			} else if n > 0 {
+				fmt.Printf("%d song(s) left, run again with: dj -f %s\n", n, *resumeFile)
+			}
+		}
+		exit(1)
+	}
+
+	// Files of this run, in playlist order
+	var downloaded []string
+	for i := 0; i < queue.total(); i++ {
+		if path, ok := files[i]; ok {
+			downloaded = append(downloaded, path)
+		}
+	}
+
+	if queue.total() == 0 {
+		fmt.Println("Error: No songs specified")
+		fmt.Println("Use -h for help")
+		exit(1)
+	}
+
+	// Find the same song downloaded from different uploads
+	if *dedupeAudio != "" && len(downloaded) > 1 {
+		removed := dedupeDownloads(ctx, dl, downloaded, *dedupeAudio == "remove")
+		var kept []string
+		for _, path := range downloaded {
+			if !removed[path] {
+				kept = append(kept, path)
+			}
+		}
+		downloaded = kept
+		for i, e := range m3uEntries {
+			if removed[e.Path] {
+				delete(m3uEntries, i)
+			}
+		}
+	}
+
+	// List each playlist's downloads for DJ software to import
+	if len(m3uEntries) > 0 {
+		written, err := writeM3UPlaylists(outDir, m3uEntries)
+		for _, path := range written {
+			fmt.Printf("%sPlaylist saved to %s%s\n", colorDim, path, colorReset)
+		}
+		if err != nil {
+			fmt.Printf("%sWarning: could not write playlist: %v%s\n", colorYellow, err, colorReset)
+		}
+		fmt.Println()
+	}
+
+	// Join the downloads into one continuous file
+	if *mergePath != "" && len(downloaded) > 0 {
+		outPath := expandHome(*mergePath)
+		fmt.Printf("%sMerging %d track(s)...%s\n", colorCyan, len(downloaded), colorReset)
+		duration, err := dl.Merge(ctx, downloaded, outPath, *crossfade)
+		if err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			exit(1)
+		}
+		fmt.Printf("  %s✓ %s (%s)%s\n\n", colorGreen, outPath, formatDuration(duration), colorReset)
+	}
+
+	// Rank the matches so likely wrong videos can be checked
+	if *matchReport != "" && len(matches) > 0 {
+		if err := writeMatchReport(expandHome(*matchReport), matches); err != nil {
+			fmt.Printf("%sWarning: could not write match report: %v%s\n\n", colorYellow, err, colorReset)
+		} else {
+			fmt.Printf("%sMatch report for %d song(s) saved to %s%s\n\n", colorDim, len(matches), *matchReport, colorReset)
+		}
+	}
+
+	// List the Spotify tracks to look for by hand
+	if *reportUnmatched != "" && len(unmatched) > 0 {
+		fmt.Printf("%s%d Spotify track(s) had no YouTube match:%s\n", colorYellow, len(unmatched), colorReset)
+		for _, u := range unmatched {
+			fmt.Printf("  %s - %s %s%s%s\n", u.Track.Artist, u.Track.Name, colorDim, u.Track.SpotifyURL, colorReset)
+		}
+		if err := writeUnmatchedReport(expandHome(*reportUnmatched), unmatched); err != nil {
+			fmt.Printf("%sWarning: could not write report: %v%s\n", colorYellow, err, colorReset)
+		} else {
+			fmt.Printf("  %sSaved to %s%s\n", colorDim, *reportUnmatched, colorReset)
+		}
+		fmt.Println()
+	}
+
+	reportFiltered(filtered, *cleanOnly)
+	artistCap.report()
+	reportProxies(dl.Proxies)
+
+	// Summary
+	batches.report()
+	failures.report()
+	if failed > 0 {
+		fmt.Printf("%sDone: %s%d downloaded%s, %s%d failed%s\n", colorBold, colorGreen, success, colorReset+colorBold, colorRed, failed, colorReset)
+		exit(1)
+	} else {
+		fmt.Printf("%sDone: %s%d downloaded%s\n", colorBold, colorGreen, success, colorReset)
+	}
+}
+
+// previewLength is how much of a match -preview plays
+const previewLength = 15 * time.Second
+
+// askPreview plays the start of a video and asks whether to download it.
+// It returns "y", "n" or "s" (skip the rest).
+func askPreview(ctx context.Context, dl *downloader.Downloader, stdin *bufio.Reader, url string) string {
+	if err := dl.Preview(ctx, url, previewLength); err != nil {
+		fmt.Printf("  %sWarning: preview failed: %v%s\n", colorYellow, err, colorReset)
+	}
+
+	for {
+		fmt.Printf("  Download this? [y/n/s(kip rest)/r(eplay)] ")
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return "s"
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(line)); answer {
+		case "", "y", "yes":
+			return "y"
+		case "n", "no", "s":
+			return answer[:1]
+		case "r":
+			dl.Preview(ctx, url, previewLength)
+		}
+	}
+}
+
+// pickPlaylist prints numbered playlists and, when run interactively, asks
+// which one to download. It returns nil if none was picked.
+func pickPlaylist(playlists []spotify.PlaylistInfo) *spotify.PlaylistInfo {
+	if len(playlists) == 0 {
+		fmt.Println("No public playlists")
+		return nil
+	}
+
+	for i, p := range playlists {
+		fmt.Printf("%s%3d.%s %s %s(%d tracks)%s\n", colorBlue, i+1, colorReset, p.Name, colorDim, p.Total, colorReset)
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\nDownload which playlist? [1-%d, Enter to quit] ", len(playlists))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" || err != nil {
+			return nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(playlists) {
+			fmt.Println()
+			return &playlists[n-1]
+		}
+		fmt.Printf("%sEnter a number from 1 to %d%s", colorYellow, len(playlists), colorReset)
+	}
+}
+
+// dedupeDownloads reports, and optionally removes, downloads that are the
+// same recording, keeping the best copy of each. It returns the removed files.
+func dedupeDownloads(ctx context.Context, dl *downloader.Downloader, files []string, remove bool) map[string]bool {
+	// A song listed twice maps to the same file, which isn't a duplicate
+	seen := make(map[string]bool)
+	var unique []string
+	for _, path := range files {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+
+	fmt.Printf("%sLooking for duplicate audio...%s\n", colorCyan, colorReset)
+	groups, err := dl.FindDuplicates(ctx, unique)
+	if err != nil {
+		fmt.Printf("  %sWarning: %v%s\n\n", colorYellow, err, colorReset)
+		return nil
+	}
+	if len(groups) == 0 {
+		fmt.Printf("  %sNo duplicates%s\n\n", colorDim, colorReset)
+		return nil
+	}
+
+	removed := make(map[string]bool)
+	for _, group := range groups {
+		fmt.Printf("  %s✓ %s%s\n", colorGreen, filepath.Base(group[0]), colorReset)
+		for _, dupe := range group[1:] {
+			if !remove {
+				fmt.Printf("    %sduplicate: %s%s\n", colorYellow, filepath.Base(dupe), colorReset)
+				continue
+			}
+			if err := dl.Cleanup(dupe); err != nil {
+				fmt.Printf("    %sWarning: could not remove %s: %v%s\n", colorYellow, filepath.Base(dupe), err, colorReset)
+				continue
+			}
+			fmt.Printf("    %sremoved: %s%s\n", colorDim, filepath.Base(dupe), colorReset)
+			removed[dupe] = true
+		}
+	}
+	fmt.Println()
+	return removed
+}
+
+// song is a single item to download
+type song struct {
+	Query string              // Search query or URL
+	Tags  downloader.Metadata // Tag overrides from the input file
+	Group string              // Source playlist name, if any
+	Batch string              // -batch-dir file the song came from, if any
+	Index int                 // 1-based position in the source playlist
+	Track *spotify.TrackInfo  // Spotify metadata, if the song came from Spotify
+}
+
+// songOutcome is how processing a song ended
+type songOutcome int
+
+const (
+	songSkipped songOutcome = iota // Passed on, e.g. at -preview or as a duplicate
+	songDone
+	songFailed
+	songStop // The user skipped the remaining songs
+)
+
+// songResult is the outcome of processing one song
+type songResult struct {
+	outcome     songOutcome
+	file        string // Downloaded file, when done
+	reason      string // Why it failed, see failureReason
+	err         error  // What failed
+	download    *downloader.DownloadResult
+	interrupted bool // Failed because the run was cancelled
+	match       *matchedSong
+	unmatched   *unmatchedTrack
+}
+
+// searchHint returns what is known about the song to guide the YouTube search
+func (s song) searchHint() downloader.SearchHint {
+	var hint downloader.SearchHint
+	if s.Track != nil {
+		hint.Duration = s.Track.DurationMs / 1000
+		hint.ISRC = s.Track.ISRC
+	}
+	return hint
+}
+
+// playlistFetchers bounds how many inputs are expanded at once
+const playlistFetchers = 4
+
+// expandInputs expands inputs into the queue, fetching several playlists at
+// once. Songs are still added in input order.
+func expandInputs(ctx context.Context, inputs []song, spotifyClient *spotify.Client, dl *downloader.Downloader, queue *songQueue, discography bool) {
+	parts := make([]*songQueue, len(inputs))
+	for i := range parts {
+		parts[i] = queue.child()
+	}
+
+	// Start fetches in input order, so the first input is never left waiting
+	go func() {
+		sem := make(chan struct{}, playlistFetchers)
+		for i, input := range inputs {
+			sem <- struct{}{}
+			go func(input song, part *songQueue) {
+				defer func() { <-sem }()
+				defer part.close()
+				expandInput(ctx, input, spotifyClient, dl, part, discography)
+			}(input, parts[i])
+		}
+	}()
+
+	for _, part := range parts {
+		for i := 0; ; i++ {
+			s, ok := part.get(i)
+			if !ok {
+				break
+			}
+			queue.add(s)
+		}
+	}
+}
+
+// expandInput expands a single input into one or more songs and adds them
+// to the queue. Spotify playlists are added page by page as they're fetched.
+func expandInput(ctx context.Context, input song, spotifyClient *spotify.Client, dl *downloader.Downloader, queue *songQueue, discography bool) {
+	input.Query = strings.TrimSpace(input.Query)
+	if input.Query == "" {
+		return
+	}
+
+	// A bare Spotify ID needs its kind, as in track:ID
+	if uri, ok := spotify.ExpandShortID(input.Query); ok {
+		input.Query = uri
+	} else if spotify.IsBareID(input.Query) {
+		fmt.Printf("%sWarning: %s looks like a Spotify ID, write it as track:%s (or playlist:, album:, artist:, episode:, show:)%s\n", colorYellow, input.Query, input.Query, colorReset)
+		return
+	}
+
+	// Check if it's a Spotify playlist URL
+	if spotify.IsSpotifyPlaylistURL(input.Query) {
+		if spotifyClient == nil {
+			fmt.Printf("%sWarning: Spotify credentials required for playlist: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		playlistID := spotify.ExtractSpotifyID(input.Query)
+		if playlistID == "" {
+			fmt.Printf("%sWarning: Could not extract playlist ID from: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		fmt.Printf("%s📋 Fetching Spotify playlist...%s\n", colorDim, colorReset)
+		playlist, tracks, errs := spotifyClient.StreamPlaylist(ctx, playlistID)
+		if playlist == nil {
+			fmt.Printf("%sWarning: Failed to fetch playlist: %v%s\n", colorYellow, <-errs, colorReset)
+			return
+		}
+		fmt.Printf("%s📋 Playlist: %s%s%s (%d tracks)\n\n", colorCyan, colorBold, playlist.Name, colorReset, playlist.Total)
+		queue.expect(playlist.Total)
+
+		// Convert tracks to search queries as they stream in. Tag overrides
+		// only make sense for a single song, so they're dropped.
+		index := 0
+		for track := range tracks {
+			index++
+			queue.add(song{Query: track.SearchQuery, Group: playlist.Name, Batch: input.Batch, Index: index, Track: &track})
+		}
+		if err := <-errs; err != nil {
+			fmt.Printf("%sWarning: Failed to fetch playlist: %v%s\n", colorYellow, err, colorReset)
+		}
+		if playlist.SkippedCount > 0 {
+			fmt.Printf("%s%d unavailable track(s) skipped (local files or removed from Spotify)%s\n", colorDim, playlist.SkippedCount, colorReset)
+		}
+		return
+	}
+
+	// Spotify albums are expanded like playlists
+	if spotify.IsSpotifyAlbumURL(input.Query) {
+		if spotifyClient == nil {
+			fmt.Printf("%sWarning: Spotify credentials required for album: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		fmt.Printf("%s💿 Fetching Spotify album...%s\n", colorDim, colorReset)
+		index := 0
+		err := spotifyClient.EachAlbumPage(ctx, spotify.ExtractSpotifyID(input.Query), func(album *spotify.AlbumInfo, tracks []spotify.TrackInfo) error {
+			if index == 0 {
+				fmt.Printf("%s💿 Album: %s%s%s by %s (%d tracks)\n\n", colorCyan, colorBold, album.Name, colorReset, album.Artist, album.Total)
+				queue.expect(album.Total)
+			}
+			for i := range tracks {
+				index++
+				queue.add(song{Query: tracks[i].SearchQuery, Group: album.Name, Batch: input.Batch, Index: index, Track: &tracks[i]})
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch album: %v%s\n", colorYellow, err, colorReset)
+		}
+		return
+	}
+
+	// Spotify artists are expanded into their top tracks, or everything
+	// they released with -discography
+	if spotify.IsSpotifyArtistURL(input.Query) {
+		if spotifyClient == nil {
+			fmt.Printf("%sWarning: Spotify credentials required for artist: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		artistID := spotify.ExtractSpotifyID(input.Query)
+		artist, err := spotifyClient.GetArtist(ctx, artistID)
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch artist: %v%s\n", colorYellow, err, colorReset)
+			return
+		}
+		var tracks []spotify.TrackInfo
+		if discography {
+			fmt.Printf("%s🎤 Fetching %s's discography...%s\n", colorDim, artist.Name, colorReset)
+			tracks, err = spotifyClient.GetArtistDiscography(ctx, artistID)
+		} else {
+			tracks, err = spotifyClient.GetArtistTopTracks(ctx, artistID)
+		}
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch artist tracks: %v%s\n", colorYellow, err, colorReset)
+			return
+		}
+
+		fmt.Printf("%s🎤 Artist: %s%s%s (%d tracks)\n\n", colorCyan, colorBold, artist.Name, colorReset, len(tracks))
+		for i := range tracks {
+			queue.add(song{Query: tracks[i].SearchQuery, Group: artist.Name, Batch: input.Batch, Index: i + 1, Track: &tracks[i]})
+		}
+		return
+	}
+
+	// Podcast episodes are searched for by their show and title, since
+	// yt-dlp can't download them from Spotify
+	if spotify.IsSpotifyEpisodeURL(input.Query) {
+		if spotifyClient == nil {
+			fmt.Printf("%sWarning: Spotify credentials required for episode: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		episode, err := spotifyClient.GetEpisode(ctx, spotify.ExtractSpotifyID(input.Query))
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch episode: %v%s\n", colorYellow, err, colorReset)
+			return
+		}
+		input.Query = episode.SearchQuery
+		input.Track = episode
+		queue.add(input)
+		return
+	}
+
+	// Podcast shows are expanded into their episodes, newest first
+	if spotify.IsSpotifyShowURL(input.Query) {
+		if spotifyClient == nil {
+			fmt.Printf("%sWarning: Spotify credentials required for show: %s%s\n", colorYellow, truncate(input.Query, 50), colorReset)
+			return
+		}
+
+		fmt.Printf("%s🎙 Fetching Spotify show...%s\n", colorDim, colorReset)
+		show, err := spotifyClient.GetShow(ctx, spotify.ExtractSpotifyID(input.Query))
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch show: %v%s\n", colorYellow, err, colorReset)
+			return
+		}
+		fmt.Printf("%s🎙 Show: %s%s%s by %s (%d episodes)\n\n", colorCyan, colorBold, show.Name, colorReset, show.Publisher, len(show.Episodes))
+		for i := range show.Episodes {
+			queue.add(song{Query: show.Episodes[i].SearchQuery, Group: show.Name, Batch: input.Batch, Index: i + 1, Track: &show.Episodes[i]})
+		}
+		return
+	}
+
+	// YouTube Music playlists and albums are listed by yt-dlp
+	if downloader.IsYouTubeMusicPlaylistURL(input.Query) {
+		fmt.Printf("%s📋 Fetching YouTube Music playlist...%s\n", colorDim, colorReset)
+		playlist, err := dl.GetYouTubePlaylist(ctx, input.Query)
+		if err != nil {
+			fmt.Printf("%sWarning: Failed to fetch playlist: %v%s\n", colorYellow, err, colorReset)
+			return
+		}
+		fmt.Printf("%s📋 Playlist: %s%s%s (%d tracks)\n\n", colorCyan, colorBold, playlist.Title, colorReset, len(playlist.Entries))
+		for i, entry := range playlist.Entries {
+			queue.add(song{Query: entry.URL, Group: playlist.Title, Batch: input.Batch, Index: i + 1})
+		}
+		return
+	}
+
+	// Not a playlist, add as-is
+	queue.add(input)
+}
+
+// reportFiltered prints how many Spotify tracks -clean-only or
+// -explicit-only left out
+func reportFiltered(filtered int, cleanOnly bool) {
+	if filtered == 0 {
+		return
+	}
+	kind := "non-explicit"
+	if cleanOnly {
+		kind = "explicit"
+	}
+	fmt.Printf("%sLeft out %d %s track(s)%s\n\n", colorDim, filtered, kind, colorReset)
+}
+
+// fileDate returns the date -set-date gives a download: the video's upload
+// date, the track's release date (from Spotify, else YouTube's release
+// year), or now
+func fileDate(mode string, result *downloader.DownloadResult, track *spotify.TrackInfo) (time.Time, bool) {
+	switch mode {
+	case "upload":
+		return result.Uploaded, !result.Uploaded.IsZero()
+	case "release":
+		if track != nil {
+			if date, ok := track.Released(); ok {
+				return date, true
+			}
+		}
+		if result.Music.Year > 0 {
+			return time.Date(result.Music.Year, time.January, 1, 0, 0, 0, 0, time.UTC), true
+		}
+		return time.Time{}, false
+	}
+	return time.Now(), true
+}
+
+// exitHooks run when dj exits through exit, as os.Exit skips deferred calls
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// onExit registers f to run when dj exits through exit
+func onExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// exit runs the onExit hooks and ends dj with the given code
+func exit(code int) {
+	exitMu.Lock()
+	for _, f := range exitHooks {
+		f()
+	}
+	exitMu.Unlock()
+	os.Exit(code)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// spotifyIDs extracts the IDs from comma-separated Spotify URLs. Bare IDs
+// are kept as they are.
+func spotifyIDs(s string) []string {
+	var ids []string
+	for _, item := range splitList(s) {
+		if id := spotify.ExtractSpotifyID(item); id != "" {
+			item = id
+		}
+		ids = append(ids, item)
+	}
+	return ids
+}
+
+// createArchive creates a download archive file if it doesn't exist, with
+// its folder, and returns its absolute path
+func createArchive(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// defaultOutputDir picks the output directory used when -o isn't given:
+// $DJ_OUTPUT_DIR, then the user's music folder, then the current directory
+func defaultOutputDir() string {
+	if dir := os.Getenv("DJ_OUTPUT_DIR"); dir != "" {
+		return expandHome(dir)
+	}
+
+	music := os.Getenv("XDG_MUSIC_DIR")
+	if music == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			music = filepath.Join(home, "Music")
+		}
+	}
+	if info, err := os.Stat(expandHome(music)); music != "" && err == nil && info.IsDir() {
+		return expandHome(music)
+	}
+	return "."
+}
+
+// expandHome expands a leading ~ to the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// loadCredentialsFile fills the Spotify credentials from a file,
+// keeping any value that was given explicitly as a flag
+func loadCredentialsFile(path string, explicit map[string]bool, clientID, clientSecret *string) error {
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0004 != 0 {
+		fmt.Printf("%sWarning: %s is world-readable, consider chmod 600%s\n", colorYellow, path, colorReset)
+	}
+
+	creds, err := spotify.LoadCredentials(path)
+	if err != nil {
+		return err
+	}
+
+	if !explicit["spotify-id"] {
+		*clientID = creds.ClientID
+	}
+	if !explicit["spotify-secret"] {
+		*clientSecret = creds.ClientSecret
+	}
+	return nil
+}
+
+// sourceTags returns the artist, title and album tags from the -tags-from
+// source, and the year for Spotify tracks. Spotify falls back to YouTube's
+// music metadata for tracks not from Spotify, which falls back to splitting
+// the video title.
+func sourceTags(source string, result *downloader.DownloadResult, track *spotify.TrackInfo) downloader.Metadata {
+	switch source {
+	case "spotify":
+		if track != nil {
+			tags := downloader.Metadata{Artist: track.Artist, Title: track.Name, Album: track.Album}
+			if date, ok := track.Released(); ok {
+				tags.Year = date.Year()
+			}
+			return tags
+		}
+		fallthrough
+	case "youtube":
+		if result.Music.Artist != "" && result.Music.Title != "" {
+			return result.Music
+		}
+		fallthrough
+	case "title":
+		return downloader.TitleMetadata(result.Title)
+	}
+	return downloader.Metadata{}
+}
+
+// mergeTags sets the non-empty fields of overrides on tags
+func mergeTags(tags *downloader.Metadata, overrides downloader.Metadata) {
+	if overrides.Artist != "" {
+		tags.Artist = overrides.Artist
+	}
+	if overrides.Title != "" {
+		tags.Title = overrides.Title
+	}
+	if overrides.Album != "" {
+		tags.Album = overrides.Album
+	}
+	if overrides.Track > 0 {
+		tags.Track = overrides.Track
+	}
+	if overrides.Year > 0 {
+		tags.Year = overrides.Year
+	}
+	if overrides.Comment != "" {
+		tags.Comment = overrides.Comment
+	}
+}
+
+// prefixedName builds a filename like "128 - 8A - Artist - Title" for the
+// given prefix mode, or "" when there is no prefix or the track's audio
+// features are unknown
+func prefixedName(mode string, track *spotify.TrackInfo) string {
+	if mode == "" || track == nil {
+		return ""
+	}
+
+	var parts []string
+	if strings.Contains(mode, "bpm") {
+		if track.BPM <= 0 {
+			return ""
+		}
+		parts = append(parts, fmt.Sprintf("%.0f", track.BPM))
+	}
+	if strings.Contains(mode, "key") {
+		if track.Camelot == "" {
+			return ""
+		}
+		parts = append(parts, track.Camelot)
+	}
+	parts = append(parts, track.Artist, track.Name)
+	return strings.Join(parts, " - ")
+}
+
+// groupDownloader returns a downloader saving into a subfolder of outDir
+// named after group, creating it on first use
+func groupDownloader(dl *downloader.Downloader, cache map[string]*downloader.Downloader, outDir, group string) (*downloader.Downloader, error) {
+	if groupDL, ok := cache[group]; ok {
+		return groupDL, nil
+	}
+
+	groupDL, err := dl.WithDownloadPath(filepath.Join(outDir, downloader.SanitizeFilename(group)))
+	if err != nil {
+		return nil, err
+	}
+	cache[group] = groupDL
+	return groupDL, nil
+}
+
+// readSongsFromFile reads songs from a text file, or an Exportify CSV export
+func readSongsFromFile(path string) ([]song, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if isExportifyCSV(path, reader) {
+		return readExportifyCSV(reader)
+	}
+
+	var songs []song
+	scanner := bufio.NewScanner(reader)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseSongLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		songs = append(songs, s)
+	}
+	return songs, scanner.Err()
+}
+
+// parseSongLine parses a line of the form
+// "query | artist=X; title=Y; album=Z", where the tag part is optional
+func parseSongLine(line string) (song, error) {
+	query, tagPart, hasTags := strings.Cut(line, "|")
+	s := song{Query: strings.TrimSpace(query)}
+	if !hasTags {
+		return s, nil
+	}
+
+	for _, field := range strings.Split(tagPart, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return s, fmt.Errorf("invalid tag %q, expected key=value", field)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "artist":
+			s.Tags.Artist = value
+		case "title":
+			s.Tags.Title = value
+		case "album":
+			s.Tags.Album = value
+		default:
+			return s, fmt.Errorf("unknown tag %q", key)
+		}
+	}
+	return s, nil
+}
+
+// download downloads a song, reporting its progress to progress if it's not
+// nil and using the prefetched search result if there is one
+func download(ctx context.Context, dl *downloader.Downloader, query string, hint downloader.SearchHint, prefetched *prefetchResult, progress downloader.ProgressCallback) (*downloader.DownloadResult, error) {
+	if prefetched != nil {
+		if prefetched.err != nil {
+			return nil, fmt.Errorf("search failed: %w", prefetched.err)
+		}
+		if progress != nil {
+			progress(downloader.Progress{Percent: 10, Status: fmt.Sprintf("Found: %s", prefetched.candidate.Title)})
+		}
+		result, err := dl.Download(ctx, prefetched.candidate.URL, progress)
+		if err != nil {
+			return nil, err
+		}
+		result.Match = prefetched.candidate
+		return result, nil
+	}
+	if downloader.IsYouTubeURL(query) {
+		return dl.Download(ctx, query, progress)
+	}
+	return dl.SearchAndDownloadWithHint(ctx, query, hint, progress)
+}
+
+// progressBar returns a callback drawing a song's download progress, as a
+// bar redrawn in place on a terminal
+func progressBar() downloader.ProgressCallback {
+	var lastPct float64
+	barWidth := 30
+	var lastStatus string
+	var lastPrinted time.Time
+
+	return func(p downloader.Progress) {
+		pct := p.Percent
+		// Don't go backwards, e.g. when a download is retried
+		if pct < lastPct {
+			pct = lastPct
+		}
+		lastPct = pct
 
 		// Calculate filled portion
 		filled := int(pct / 100 * float64(barWidth))
@@ -277,14 +2113,26 @@ func download(ctx context.Context, dl *downloader.Downloader, query string) (*do
 		// Build colored progress bar
 		bar := colorGreen + strings.Repeat("█", filled) + colorDim + strings.Repeat("░", barWidth-filled) + colorReset
 
-		// Format status/speed display
+		// Show the speed and time left while downloading, else the status
 		statusDisplay := ""
-		if strings.Contains(status, "/s") {
-			// It's a speed indicator
-			statusDisplay = fmt.Sprintf(" %s%s%s", colorCyan, status, colorReset)
-		} else if status != "" {
-			// It's a status message - show dimmed
-			statusDisplay = fmt.Sprintf(" %s%s%s", colorDim, truncate(status, 40), colorReset)
+		if p.Speed > 0 {
+			statusDisplay = fmt.Sprintf(" %s%s/s%s", colorCyan, formatSize(int64(p.Speed)), colorReset)
+			if p.ETA > 0 {
+				statusDisplay += fmt.Sprintf(" %s%s left%s", colorDim, formatDuration(p.ETA), colorReset)
+			}
+		} else if p.Status != "" {
+			statusDisplay = fmt.Sprintf(" %s%s%s", colorDim, truncate(p.Status, 40), colorReset)
+		}
+
+		// A log file would collect every redraw, so print a line now and
+		// then instead, and whenever the status changes
+		if !interactive {
+			if pct < 100 && p.Status == lastStatus && time.Since(lastPrinted) < progressLogInterval {
+				return
+			}
+			lastStatus, lastPrinted = p.Status, time.Now()
+			fmt.Printf("  %3.0f%%%s\n", pct, statusDisplay)
+			return
 		}
 
 		// Print with carriage return to overwrite (add padding to clear old content)
@@ -295,13 +2143,12 @@ func download(ctx context.Context, dl *downloader.Downloader, query string) (*do
 			fmt.Println()
 		}
 	}
-
-	if downloader.IsYouTubeURL(query) {
-		return dl.Download(ctx, query, progress)
-	}
-	return dl.SearchAndDownload(ctx, query, progress)
 }
 
+// progressLogInterval is how often progress is printed when stdout isn't a
+// terminal
+const progressLogInterval = 5 * time.Second
+
 // truncate shortens a string
 func truncate(s string, max int) string {
 	if len(s) <= max {
@@ -309,3 +2156,26 @@ func truncate(s string, max int) string {
 	}
 	return s[:max-3] + "..."
 }
+
+// formatDuration formats a track length as m:ss
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// parseTimestamp parses a position like 90, 1:30, 1:02:03 or 1m30s
+func parseTimestamp(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	var total time.Duration
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a time like 1:30", s)
+		}
+		total = total*60 + time.Duration(n*float64(time.Second))
+	}
+	return total, nil
+}
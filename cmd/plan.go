@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// printPlan prints the video a song would download and the path it would be
+// saved at, in dir if set, for -dry-run
+func printPlan(ctx context.Context, dl *downloader.Downloader, s song, filenamePrefix, dir string) {
+	query := s.Query
+	if s.Track != nil {
+		query = s.Track.SearchQuery
+		fmt.Printf("  %s→ %s - %s", colorDim, s.Track.Artist, s.Track.Name)
+		if s.Track.BPM > 0 {
+			fmt.Printf(" [%.0f BPM, %s]", s.Track.BPM, s.Track.Key)
+		}
+		fmt.Printf("%s\n", colorReset)
+	}
+
+	var match *downloader.Candidate
+	if downloader.IsYouTubeURL(query) {
+		title, artist, duration, err := dl.GetVideoInfo(ctx, query)
+		if err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			return
+		}
+		if artist != "" {
+			title = artist + " - " + title
+		}
+		match = &downloader.Candidate{URL: query, Title: title, Duration: duration}
+	} else {
+		var err error
+		if match, err = dl.ResolveWithHint(ctx, query, s.searchHint()); err != nil {
+			fmt.Printf("  %s✗ %v%s\n", colorRed, err, colorReset)
+			return
+		}
+	}
+
+	fmt.Printf("  %s▶ %s", colorDim, match.Title)
+	if match.Duration > 0 {
+		fmt.Printf(" (%s)", formatDuration(time.Duration(match.Duration)*time.Second))
+	}
+	fmt.Printf(" %s%s\n", match.URL, colorReset)
+
+	path := dl.PlannedPath(match, prefixedName(filenamePrefix, s.Track))
+	if dir != "" {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	fmt.Printf("  %s↓ %s%s\n", colorGreen, path, colorReset)
+}
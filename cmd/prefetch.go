@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// prefetchResult is the outcome of a background search
+type prefetchResult struct {
+	candidate *downloader.Candidate
+	err       error
+}
+
+// prefetcher resolves YouTube searches for upcoming songs in the background,
+// so the next download can start as soon as the current one finishes.
+// It is only used from the main goroutine.
+type prefetcher struct {
+	ctx       context.Context
+	dl        *downloader.Downloader
+	songs     *songQueue
+	lookahead int
+	pending   map[int]chan prefetchResult
+}
+
+// newPrefetcher creates a prefetcher looking up to lookahead songs ahead
+func newPrefetcher(ctx context.Context, dl *downloader.Downloader, songs *songQueue, lookahead int) *prefetcher {
+	return &prefetcher{
+		ctx:       ctx,
+		dl:        dl,
+		songs:     songs,
+		lookahead: lookahead,
+		pending:   make(map[int]chan prefetchResult),
+	}
+}
+
+// prefetch starts searches for the songs after index i that aren't started
+// yet. Songs not in the queue yet are left for a later call.
+func (p *prefetcher) prefetch(i int) {
+	for j := i + 1; j <= i+p.lookahead; j++ {
+		s, ok := p.songs.peek(j)
+		if !ok {
+			break
+		}
+		if _, started := p.pending[j]; started || !isSearchQuery(s.Query) {
+			continue
+		}
+
+		results := make(chan prefetchResult, 1)
+		p.pending[j] = results
+		go func(s song) {
+			candidate, err := p.dl.ResolveWithHint(p.ctx, s.Query, s.searchHint())
+			results <- prefetchResult{candidate, err}
+		}(s)
+	}
+}
+
+// take waits for the prefetched search of song i, if one was started
+func (p *prefetcher) take(i int) (prefetchResult, bool) {
+	results, ok := p.pending[i]
+	if !ok {
+		return prefetchResult{}, false
+	}
+	delete(p.pending, i)
+	return <-results, true
+}
+
+// isSearchQuery reports whether a query is plain text to search for
+func isSearchQuery(query string) bool {
+	return !downloader.IsYouTubeURL(query) && !spotify.IsSpotifyURL(query)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// project is a saved download job: a set of inputs plus options, loaded
+// from a .dj file
+type project struct {
+	Inputs  []string
+	Options [][2]string // Flag name and value, in file order
+}
+
+// loadProject reads a .dj project file. It uses the flat `key = "value"`
+// subset of TOML, where each key is a flag name and `input` may repeat:
+//
+//	# friday.dj
+//	o = "~/Music/friday"
+//	filename-prefix = "bpm-key"
+//	no-live = true
+//	input = "https://open.spotify.com/playlist/..."
+//	input = "Daft Punk - Around The World"
+func loadProject(path string) (*project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &project{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		switch {
+		case key == "input":
+			p.Inputs = append(p.Inputs, value)
+		case key == "project" || flag.Lookup(key) == nil:
+			return nil, fmt.Errorf("%s:%d: unknown option %q", path, lineNo, key)
+		default:
+			p.Options = append(p.Options, [2]string{key, value})
+		}
+	}
+	return p, scanner.Err()
+}
+
+// apply sets the project's options, except those in explicit, which were
+// given on the command line and take precedence
+func (p *project) apply(explicit map[string]bool) error {
+	for _, opt := range p.Options {
+		if explicit[opt[0]] {
+			continue
+		}
+		if err := flag.Set(opt[0], opt[1]); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", opt[1], opt[0], err)
+		}
+	}
+	return nil
+}
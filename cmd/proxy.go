@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// readProxyList reads proxy URLs from a file, one per line. Blank lines and
+// lines starting with # are skipped.
+func readProxyList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var proxies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxies in %s", path)
+	}
+	return proxies, nil
+}
+
+// reportProxies prints how each proxy of -proxy-list fared
+func reportProxies(pool *downloader.ProxyPool) {
+	if pool == nil {
+		return
+	}
+
+	statuses := pool.Status()
+	healthy := 0
+	for _, s := range statuses {
+		if s.Healthy() {
+			healthy++
+		}
+	}
+	fmt.Printf("%sProxies: %d of %d healthy%s\n", colorBold, healthy, len(statuses), colorReset)
+	for _, s := range statuses {
+		if s.Healthy() {
+			fmt.Printf("  %s✓ %s%s %s(%d download(s))%s\n", colorGreen, s.Proxy, colorReset, colorDim, s.Downloads, colorReset)
+		} else {
+			fmt.Printf("  %s✗ %s%s %s%s%s\n", colorRed, s.Proxy, colorReset, colorDim, truncate(s.LastError, 60), colorReset)
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,102 @@
+package main
+
+import "sync"
+
+// songQueue is the list of songs to download. With -fetch-parallel it keeps
+// growing while playlists are fetched, so downloads can start right away.
+type songQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	songs    []song
+	expected int // Songs announced (e.g. a playlist's total) but not added yet
+	closed   bool
+	parent   *songQueue // Also told about expected songs, see child
+
+	// keep, if set, decides which added songs are kept
+	keep func(song) bool
+}
+
+// newSongQueue creates an empty, open queue
+func newSongQueue() *songQueue {
+	q := &songQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// child returns a queue for songs that will be moved into q later. The songs
+// it expects are announced to q right away, so q's total stays accurate.
+func (q *songQueue) child() *songQueue {
+	c := newSongQueue()
+	c.parent = q
+	return c
+}
+
+// add appends songs to the queue
+func (q *songQueue) add(songs ...song) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, s := range songs {
+		if q.keep == nil || q.keep(s) {
+			q.songs = append(q.songs, s)
+		}
+	}
+	q.expected = max(q.expected-len(songs), 0)
+	q.cond.Broadcast()
+}
+
+// expect announces n more songs that will be added later
+func (q *songQueue) expect(n int) {
+	q.mu.Lock()
+	q.expected = max(q.expected+n, 0)
+	q.mu.Unlock()
+
+	if q.parent != nil {
+		q.parent.expect(n)
+	}
+}
+
+// close marks the queue complete, waking any waiting get. Songs announced
+// but never added are taken back from the parent.
+func (q *songQueue) close() {
+	q.mu.Lock()
+	missing := q.expected
+	q.closed = true
+	q.expected = 0
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	if q.parent != nil && missing > 0 {
+		q.parent.expect(-missing)
+	}
+}
+
+// get returns song i, waiting for it to be added. It returns false once the
+// queue is closed with fewer songs.
+func (q *songQueue) get(i int) (song, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i >= len(q.songs) && !q.closed {
+		q.cond.Wait()
+	}
+	if i >= len(q.songs) {
+		return song{}, false
+	}
+	return q.songs[i], true
+}
+
+// peek returns song i if it has been added, without waiting
+func (q *songQueue) peek(i int) (song, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i >= len(q.songs) {
+		return song{}, false
+	}
+	return q.songs[i], true
+}
+
+// total returns the number of songs, including announced ones
+func (q *songQueue) total() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.songs) + q.expected
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// unmatchedTrack is a Spotify track that couldn't be found on YouTube
+type unmatchedTrack struct {
+	Track  *spotify.TrackInfo
+	Reason string
+}
+
+// writeUnmatchedReport writes the unmatched tracks to a tab-separated file:
+// artist - title, Spotify URL and the reason it wasn't matched
+func writeUnmatchedReport(path string, tracks []unmatchedTrack) error {
+	var b strings.Builder
+	b.WriteString("# Spotify tracks with no YouTube match\n")
+	for _, u := range tracks {
+		fmt.Fprintf(&b, "%s - %s\t%s\t%s\n", u.Track.Artist, u.Track.Name, u.Track.SpotifyURL, u.Reason)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// matchedSong is a search query and the video picked for it
+type matchedSong struct {
+	Query string
+	Match *downloader.Candidate
+}
+
+// writeMatchReport writes the matches to a CSV file, least confident first,
+// with the components of each match's confidence
+func writeMatchReport(path string, matches []matchedSong) error {
+	sorted := append([]matchedSong(nil), matches...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Match.Confidence() < sorted[j].Match.Confidence()
+	})
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"confidence", "query", "title", "channel", "url", "title_match", "duration_diff", "trusted_channel", "rank", "live", "variant", "score"})
+	for _, m := range sorted {
+		c := m.Match
+		durationDiff := ""
+		if c.DurationDiff >= 0 {
+			durationDiff = strconv.Itoa(c.DurationDiff)
+		}
+		w.Write([]string{
+			strconv.FormatFloat(c.Confidence(), 'f', 2, 64),
+			m.Query,
+			c.Title,
+			c.Channel,
+			c.URL,
+			strconv.FormatFloat(c.Match, 'f', 2, 64),
+			durationDiff,
+			strconv.FormatBool(c.TrustedChannel()),
+			strconv.Itoa(c.Rank),
+			strconv.FormatFloat(c.Live, 'f', 0, 64),
+			strconv.FormatFloat(c.Variant, 'f', 0, 64),
+			strconv.FormatFloat(c.Score, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// writeResumeFile writes the songs of the queue that aren't finished to
+// path, one per line, so the remaining work can be run again with -f. It
+// returns the number of songs written, and writes nothing if there are none.
+func writeResumeFile(path string, queue *songQueue, finished map[int]bool) (int, error) {
+	var lines []string
+	for i := 0; ; i++ {
+		s, ok := queue.peek(i)
+		if !ok {
+			break
+		}
+		if !finished[i] {
+			lines = append(lines, songLine(s))
+		}
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+
+	data := strings.Join(lines, "\n") + "\n"
+	return len(lines), os.WriteFile(path, []byte(data), 0644)
+}
+
+// songLine formats a song as a line of a songs file, see parseSongLine.
+// Spotify tracks are written as their URL, to keep their metadata.
+func songLine(s song) string {
+	line := s.Query
+	if s.Track != nil && s.Track.SpotifyURL != "" {
+		line = s.Track.SpotifyURL
+	}
+
+	var tags []string
+	for _, tag := range []struct{ key, value string }{
+		{"artist", s.Tags.Artist},
+		{"title", s.Tags.Title},
+		{"album", s.Tags.Album},
+	} {
+		if tag.value != "" {
+			tags = append(tags, tag.key+"="+tag.value)
+		}
+	}
+	if len(tags) > 0 {
+		line += " | " + strings.Join(tags, "; ")
+	}
+	return line
+}
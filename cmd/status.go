@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+)
+
+// statusRedrawInterval is how often the status line is redrawn on a terminal
+const statusRedrawInterval = 500 * time.Millisecond
+
+// statusLine sums up a -jobs run on one line, e.g.
+// "Active: 3 · Done: 12 · Failed: 1 · Queued: 34 · 4.1 MiB/s total".
+// On a terminal it stays at the bottom, below the songs' logs. Otherwise a
+// plain line is printed every progressLogInterval.
+type statusLine struct {
+	mu     sync.Mutex
+	w      *os.File
+	tty    bool
+	total  func() int      // Songs queued so far, done or not
+	active map[int]float64 // Download speed of each active song by index, bytes per second
+	done   int
+	failed int
+	shown  bool // Whether the line is on screen and must be cleared before printing
+	hidden bool // Never drawn, when quiet
+}
+
+// newStatusLine creates a status line drawn on w for a queue of total songs
+func newStatusLine(w *os.File, total func() int) *statusLine {
+	return &statusLine{w: w, tty: isTerminal(w), total: total, active: make(map[int]float64)}
+}
+
+// run redraws the line until ctx is done, then clears it
+func (s *statusLine) run(ctx context.Context) {
+	interval := progressLogInterval
+	if s.tty {
+		interval = statusRedrawInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.clear()
+			s.mu.Unlock()
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.draw()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// start marks song i as active
+func (s *statusLine) start(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[i] = 0
+}
+
+// progress returns a callback keeping song i's download speed
+func (s *statusLine) progress(i int) downloader.ProgressCallback {
+	return func(p downloader.Progress) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.active[i]; ok {
+			s.active[i] = p.Speed
+		}
+	}
+}
+
+// finish marks song i as done, or failed
+func (s *statusLine) finish(i int, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, i)
+	if failed {
+		s.failed++
+	} else {
+		s.done++
+	}
+}
+
+// print writes a song's log to stdout above the line
+func (s *statusLine) print(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+	os.Stdout.Write(p)
+	if s.tty {
+		s.draw()
+	}
+}
+
+// String formats the counts and the total download speed
+func (s *statusLine) String() string {
+	var speed float64
+	for _, v := range s.active {
+		speed += v
+	}
+	queued := s.total() - len(s.active) - s.done - s.failed
+	if queued < 0 {
+		queued = 0
+	}
+	return fmt.Sprintf("Active: %d · Done: %d · Failed: %d · Queued: %d · %s/s total", len(s.active), s.done, s.failed, queued, formatSize(int64(speed)))
+}
+
+// draw prints the line, over itself on a terminal. The caller holds mu.
+func (s *statusLine) draw() {
+	if s.hidden {
+		return
+	}
+	if !s.tty {
+		fmt.Fprintf(s.w, "%s\n", s)
+		return
+	}
+	fmt.Fprintf(s.w, "\r\033[K%s%s%s", colorCyan, s, colorReset)
+	s.shown = true
+}
+
+// clear removes the line from a terminal. The caller holds mu.
+func (s *statusLine) clear() {
+	if s.shown {
+		fmt.Fprint(s.w, "\r\033[K")
+		s.shown = false
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/dj-bot/internal/downloader"
+	"github.com/yourusername/dj-bot/internal/spotify"
+)
+
+// renderTemplate replaces {name} tokens with their values.
+// Unknown tokens are left as they are.
+func renderTemplate(tmpl string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// templateVars returns the tokens available to templates for a downloaded song
+func templateVars(result *downloader.DownloadResult, track *spotify.TrackInfo) map[string]string {
+	vars := map[string]string{
+		"date":   time.Now().Format("2006-01-02"),
+		"source": result.YouTubeURL,
+		"title":  result.Title,
+		"artist": result.Artist,
+		"bpm":    "",
+		"key":    "",
+	}
+
+	if track != nil {
+		if track.SpotifyURL != "" {
+			vars["source"] = track.SpotifyURL
+		}
+		vars["title"] = track.Name
+		vars["artist"] = track.Artist
+		if track.BPM > 0 {
+			vars["bpm"] = fmt.Sprintf("%.0f", track.BPM)
+		}
+		vars["key"] = track.Key
+	}
+	return vars
+}
@@ -0,0 +1,265 @@
+package downloader
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Analysis settings. Audio is decoded to mono at a low sample rate, which
+// keeps both the beat and the notes used for key detection.
+const (
+	analysisRate     = 11025
+	tempoHop         = 128  // Samples per onset frame (~12ms)
+	keyFrame         = 8192 // Samples per chroma frame (~0.75s)
+	minTempo         = 60.0
+	maxTempo         = 200.0
+	preferredTempo   = 120.0 // Center of the tempo weighting, in BPM
+	tempoMultiples   = 8     // Beat periods used to refine the tempo
+	lowestKeyPitch   = 36    // MIDI note C2
+	highestKeyPitch  = 95    // MIDI note B6
+	analysisMaxInput = 10 * time.Minute
+)
+
+// Krumhansl-Kessler key profiles, starting from the tonic
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// Analysis is the tempo and key detected in an audio file
+type Analysis struct {
+	BPM           float64
+	BPMConfidence float64 // 0..1
+	Key           int     // Pitch class, 0 = C
+	Mode          int     // 1 = major, 0 = minor, as in Spotify's audio features
+	KeyConfidence float64 // 0..1
+}
+
+// Analyze detects the tempo and key of an audio file. It reads at most the
+// first 10 minutes.
+func (d *Downloader) Analyze(ctx context.Context, filePath string) (*Analysis, error) {
+	return d.analyze(ctx, filePath, analysisMaxInput)
+}
+
+// AnalyzeURL detects the tempo and key of a video from the first part of its
+// audio stream, without downloading the whole video
+func (d *Downloader) AnalyzeURL(ctx context.Context, videoURL string, length time.Duration) (*Analysis, error) {
+	streamURL, err := d.streamURL(ctx, videoURL, "bestaudio/best")
+	if err != nil {
+		return nil, err
+	}
+	return d.analyze(ctx, streamURL, length)
+}
+
+// analyze detects the tempo and key of up to length of an input's audio
+func (d *Downloader) analyze(ctx context.Context, input string, length time.Duration) (*Analysis, error) {
+	samples, err := d.decodeMono(ctx, input, length)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < keyFrame*4 {
+		return nil, fmt.Errorf("too short to analyze")
+	}
+
+	a := &Analysis{}
+	a.BPM, a.BPMConfidence = detectTempo(samples)
+	a.Key, a.Mode, a.KeyConfidence = detectKey(samples)
+	return a, nil
+}
+
+// decodeMono decodes up to length of an audio file or URL into mono samples
+// at analysisRate
+func (d *Downloader) decodeMono(ctx context.Context, input string, length time.Duration) ([]float32, error) {
+	cmd := d.ffmpeg(ctx,
+		"-v", "error",
+		"-i", input,
+		"-t", fmt.Sprintf("%.0f", length.Seconds()),
+		"-ac", "1",
+		"-ar", fmt.Sprint(analysisRate),
+		"-f", "f32le",
+		"-",
+	)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+
+	samples := make([]float32, len(output)/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(output[i*4:]))
+	}
+	return samples, nil
+}
+
+// detectTempo estimates BPM from the autocorrelation of an onset envelope
+// (rises in loudness), favoring tempos near preferredTempo. Confidence is how
+// much the winning period stands out.
+func detectTempo(samples []float32) (bpm, confidence float64) {
+	// Onset strength: rise in log energy from one frame to the next
+	frames := len(samples) / tempoHop
+	onsets := make([]float64, frames)
+	prev := 0.0
+	for i := 0; i < frames; i++ {
+		energy := 0.0
+		for _, s := range samples[i*tempoHop : (i+1)*tempoHop] {
+			energy += float64(s) * float64(s)
+		}
+		level := math.Log1p(1000 * energy)
+		if i > 0 {
+			onsets[i] = math.Max(level-prev, 0)
+		}
+		prev = level
+	}
+	mean := 0.0
+	for _, o := range onsets {
+		mean += o
+	}
+	mean /= float64(frames)
+	for i := range onsets {
+		onsets[i] -= mean
+	}
+
+	// Autocorrelate over the lags of the tempo range
+	fps := float64(analysisRate) / tempoHop
+	minLag := int(60 * fps / maxTempo)
+	maxLag := int(60*fps/minTempo) + 1
+	corr := make([]float64, maxLag+1)
+	for lag := range corr {
+		corr[lag] = autocorrelation(onsets, lag)
+	}
+	if corr[0] <= 0 {
+		return 0, 0
+	}
+
+	best, bestScore, sum := minLag, math.Inf(-1), 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		sum += corr[lag]
+		octaves := math.Log2(60 * fps / float64(lag) / preferredTempo)
+		score := corr[lag] * math.Exp(-0.5*octaves*octaves)
+		if score > bestScore {
+			best, bestScore = lag, score
+		}
+	}
+
+	// Refine the period from the peaks at its multiples, which pin it down
+	// to a fraction of a frame
+	var num, den float64
+	for k := 1; k <= tempoMultiples; k++ {
+		center := k * best
+		if center+k+1 >= frames {
+			break
+		}
+		peak, peakCorr := center, math.Inf(-1)
+		for lag := center - k; lag <= center+k; lag++ {
+			if c := autocorrelation(onsets, lag); c > peakCorr {
+				peak, peakCorr = lag, c
+			}
+		}
+		// Interpolate between lags with a parabola through the neighbors
+		at := float64(peak)
+		a, b, c := autocorrelation(onsets, peak-1), peakCorr, autocorrelation(onsets, peak+1)
+		if a-2*b+c < 0 {
+			at += 0.5 * (a - c) / (a - 2*b + c)
+		}
+		num += float64(k) * at
+		den += float64(k * k)
+	}
+	bpm = math.Round(60*fps/(num/den)*10) / 10
+
+	average := sum / float64(maxLag-minLag+1)
+	confidence = (corr[best] - average) / (corr[0] - average)
+	return bpm, math.Max(0, math.Min(confidence, 1))
+}
+
+// autocorrelation returns the correlation of x with itself shifted by lag
+func autocorrelation(x []float64, lag int) float64 {
+	sum := 0.0
+	for i := lag; i < len(x); i++ {
+		sum += x[i] * x[i-lag]
+	}
+	return sum
+}
+
+// detectKey finds the key whose Krumhansl-Kessler profile best correlates
+// with the track's overall chroma (energy per pitch class). Confidence is
+// that correlation.
+func detectKey(samples []float32) (key, mode int, confidence float64) {
+	window := make([]float64, keyFrame)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(keyFrame-1))
+	}
+
+	var chroma [12]float64
+	frame := make([]float64, keyFrame)
+	for start := 0; start+keyFrame <= len(samples); start += keyFrame {
+		for i := range frame {
+			frame[i] = float64(samples[start+i]) * window[i]
+		}
+
+		// Goertzel power of each note, normalized per frame so loud
+		// passages don't dominate
+		var frameChroma [12]float64
+		total := 0.0
+		for pitch := lowestKeyPitch; pitch <= highestKeyPitch; pitch++ {
+			freq := 440 * math.Pow(2, float64(pitch-69)/12)
+			power := goertzel(frame, freq/analysisRate)
+			frameChroma[pitch%12] += math.Sqrt(power)
+			total += math.Sqrt(power)
+		}
+		if total == 0 {
+			continue
+		}
+		for i := range chroma {
+			chroma[i] += frameChroma[i] / total
+		}
+	}
+
+	confidence = -1
+	for tonic := 0; tonic < 12; tonic++ {
+		for m, profile := range [2][12]float64{minorProfile, majorProfile} {
+			if r := correlation(chroma, profile, tonic); r > confidence {
+				key, mode, confidence = tonic, m, r
+			}
+		}
+	}
+	return key, mode, math.Max(confidence, 0)
+}
+
+// goertzel returns the power of x at a frequency given in cycles per sample
+func goertzel(x []float64, freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq)
+	var s1, s2 float64
+	for _, v := range x {
+		s1, s2 = v+coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// correlation returns the Pearson correlation of chroma with a key profile
+// rotated to start on tonic
+func correlation(chroma, profile [12]float64, tonic int) float64 {
+	var meanC, meanP float64
+	for i := 0; i < 12; i++ {
+		meanC += chroma[i] / 12
+		meanP += profile[i] / 12
+	}
+
+	var cov, varC, varP float64
+	for i := 0; i < 12; i++ {
+		c := chroma[(tonic+i)%12] - meanC
+		p := profile[i] - meanP
+		cov += c * p
+		varC += c * c
+		varP += p * p
+	}
+	if varC == 0 || varP == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varC*varP)
+}
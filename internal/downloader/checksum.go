@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumExt is appended to a file's path for its checksum sidecar
+const checksumExt = ".sha256"
+
+// existingDownload returns the result for a stable-named file that was
+// already downloaded and is intact, or nil if it must be (re)downloaded
+func (d *Downloader) existingDownload(url string) *DownloadResult {
+	id := ExtractYouTubeID(url)
+	if id == "" {
+		return nil
+	}
+
+	filePath := filepath.Join(d.downloadPath, id+d.audioExt())
+	if ok, err := verifyChecksum(filePath); err != nil || !ok {
+		return nil
+	}
+
+	return &DownloadResult{
+		FilePath:   filePath,
+		Title:      id,
+		YouTubeURL: url,
+		Skipped:    true,
+	}
+}
+
+// fileChecksum returns the hex SHA-256 of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksum records a file's checksum in sha256sum format next to it
+func writeChecksum(path string) error {
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return os.WriteFile(path+checksumExt, []byte(line), 0644)
+}
+
+// verifyChecksum reports whether a file matches its recorded checksum
+func verifyChecksum(path string) (bool, error) {
+	data, err := os.ReadFile(path + checksumExt)
+	if err != nil {
+		return false, err
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+
+	got, err := fileChecksum(path)
+	if err != nil {
+		return false, err
+	}
+	return got == want, nil
+}
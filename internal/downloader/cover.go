@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// EmbedCover downloads an image and embeds it as the file's cover art,
+// replacing any existing one
+func (d *Downloader) EmbedCover(ctx context.Context, filePath, imageURL string) error {
+	imagePath, err := d.downloadImage(ctx, imageURL)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(imagePath)
+
+	return d.embedImage(ctx, filePath, imagePath)
+}
+
+// EmbedFrame grabs the frame at the given offset of a YouTube video and
+// embeds it as the file's cover art. Only the frame itself is downloaded.
+func (d *Downloader) EmbedFrame(ctx context.Context, filePath, videoURL string, at time.Duration) error {
+	// Resolve the direct URL of a video stream so ffmpeg can seek in it
+	streamURL, err := d.streamURL(ctx, videoURL, "bestvideo[height<=1080]/bestvideo")
+	if err != nil {
+		return fmt.Errorf("no video stream to take a frame from")
+	}
+
+	f, err := os.CreateTemp("", "dj-frame-*.jpg")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cmd := d.ffmpeg(ctx,
+		"-y",
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+		"-i", streamURL,
+		"-frames:v", "1",
+		"-q:v", "2",
+		f.Name(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to grab frame: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return d.embedImage(ctx, filePath, f.Name())
+}
+
+// squareCrop crops cover art to a centered square, the shape DJ software
+// shows it in, cutting off the bars of letterboxed video frames
+const squareCrop = "crop='min(iw,ih)':'min(iw,ih)'"
+
+// embedImage embeds an image file, cropped square, as the file's cover art
+func (d *Downloader) embedImage(ctx context.Context, filePath, imagePath string) error {
+	args := []string{
+		"-y",
+		"-v", "error",
+		"-i", filePath,
+		"-i", imagePath,
+		"-map", "0:a",
+		"-map", "1:v",
+		"-c:a", "copy",
+		"-c:v", "mjpeg",
+		"-q:v", "2",
+		"-vf", squareCrop,
+	}
+	args = append(args, d.tagArgs(filePath)...)
+	args = append(args,
+		"-metadata:s:v", "title=Album cover",
+		"-metadata:s:v", "comment=Cover (front)",
+		"-disposition:v", "attached_pic",
+	)
+	return d.rewrite(ctx, filePath, args)
+}
+
+// httpClient returns the client for requests made outside yt-dlp, going
+// through Proxy if set
+func (d *Downloader) httpClient() (*http.Client, error) {
+	if d.Proxy == "" {
+		return http.DefaultClient, nil
+	}
+	proxyURL, err := url.Parse(d.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", d.Proxy, err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}, nil
+}
+
+// downloadImage saves an image URL to a temp file and returns its path
+func (d *Downloader) downloadImage(ctx context.Context, imageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := d.httpClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch cover: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch cover: %s", resp.Status)
+	}
+
+	f, err := os.CreateTemp("", "dj-cover-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to fetch cover: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,33 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDownloadImageProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("jpeg"))
+	}))
+	t.Cleanup(proxy.Close)
+
+	d := &Downloader{Proxy: proxy.URL}
+	const coverURL = "http://i.scdn.co.invalid/image/ab67616d0000b273"
+	path, err := d.downloadImage(context.Background(), coverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	if proxied != coverURL {
+		t.Errorf("proxy got %q, want %q", proxied, coverURL)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "jpeg" {
+		t.Errorf("image = %q, want the proxy's response", data)
+	}
+}
@@ -0,0 +1,133 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+)
+
+// Thresholds for treating two fingerprints as the same recording
+const (
+	duplicateSimilarity  = 0.85 // Share of matching fingerprint bits
+	duplicateMaxShift    = 80   // Alignment search, in fingerprint items (~10s)
+	duplicateMaxDuration = 10.0 // Max length difference in seconds
+)
+
+// audioPrint is a file's Chromaprint fingerprint
+type audioPrint struct {
+	Path        string
+	Duration    float64  `json:"duration"`
+	Fingerprint []uint32 `json:"fingerprint"`
+}
+
+// FindDuplicates groups files holding the same recording, by comparing
+// Chromaprint fingerprints (requires fpcalc). Each group lists the file to
+// keep first: the highest bitrate, then the largest. Files must be distinct.
+func (d *Downloader) FindDuplicates(ctx context.Context, files []string) ([][]string, error) {
+	fpcalcPath, err := exec.LookPath("fpcalc")
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc not found in PATH (install chromaprint): %w", err)
+	}
+
+	var prints []audioPrint
+	for _, path := range files {
+		output, err := exec.CommandContext(ctx, fpcalcPath, "-raw", "-json", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("fpcalc failed for %s: %w", path, err)
+		}
+		fp := audioPrint{Path: path}
+		if err := json.Unmarshal(output, &fp); err != nil {
+			return nil, fmt.Errorf("unexpected fpcalc output for %s: %w", path, err)
+		}
+		prints = append(prints, fp)
+	}
+
+	// Group each file with the first earlier file it matches
+	var groups [][]string
+	grouped := make(map[int]int) // File index to group index
+	for i := range prints {
+		for j := 0; j < i; j++ {
+			if !sameRecording(prints[i], prints[j]) {
+				continue
+			}
+			if g, ok := grouped[j]; ok {
+				groups[g] = append(groups[g], prints[i].Path)
+				grouped[i] = g
+			} else {
+				grouped[j], grouped[i] = len(groups), len(groups)
+				groups = append(groups, []string{prints[j].Path, prints[i].Path})
+			}
+			break
+		}
+	}
+
+	for _, group := range groups {
+		d.sortByQuality(ctx, group)
+	}
+	return groups, nil
+}
+
+// sameRecording reports whether two fingerprints are of the same audio,
+// allowing for a shifted start (e.g. a longer intro)
+func sameRecording(a, b audioPrint) bool {
+	diff := a.Duration - b.Duration
+	if diff > duplicateMaxDuration || diff < -duplicateMaxDuration {
+		return false
+	}
+
+	for shift := -duplicateMaxShift; shift <= duplicateMaxShift; shift++ {
+		if fingerprintSimilarity(a.Fingerprint, b.Fingerprint, shift) >= duplicateSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintSimilarity returns the share of equal bits between a and b,
+// with b shifted by the given number of items
+func fingerprintSimilarity(a, b []uint32, shift int) float64 {
+	matched, total := 0, 0
+	for i := range a {
+		j := i + shift
+		if j < 0 || j >= len(b) {
+			continue
+		}
+		matched += 32 - bits.OnesCount32(a[i]^b[j])
+		total += 32
+	}
+
+	// Too little overlap to tell
+	if total < 32*len(a)/2 || total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
+
+// sortByQuality orders files by bitrate, then size, best first
+func (d *Downloader) sortByQuality(ctx context.Context, files []string) {
+	type quality struct{ bitrate, size int64 }
+	qualities := make(map[string]quality)
+	for _, path := range files {
+		var q quality
+		if output, err := d.ffprobe(ctx, "-show_entries", "format=bit_rate", "-of", "default=noprint_wrappers=1:nokey=1", path); err == nil {
+			q.bitrate, _ = strconv.ParseInt(output, 10, 64)
+		}
+		if info, err := os.Stat(path); err == nil {
+			q.size = info.Size()
+		}
+		qualities[path] = q
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := qualities[files[i]], qualities[files[j]]
+		if a.bitrate != b.bitrate {
+			return a.bitrate > b.bitrate
+		}
+		return a.size > b.size
+	})
+}
@@ -3,20 +3,117 @@ package downloader
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Downloader handles downloading audio from YouTube
 type Downloader struct {
 	downloadPath string
-	ytdlpPath    string
-	ffmpegPath   string
+	ytdlpCmd     []string // Program and any leading args, e.g. python -m yt_dlp
+	ffmpegCmd    []string
+	staging      *staging // Shared with clones, see Close
+
+	// YtDlpVersion and FFmpegVersion are the tools' versions, as read by
+	// New. FFmpegVersion is "" if ffmpeg's banner wasn't recognized.
+	YtDlpVersion  string
+	FFmpegVersion string
+
+	// AudioFormat is the format audio is converted to: mp3 (the default),
+	// opus, flac, m4a or wav
+	AudioFormat string
+
+	// AudioQuality is the bitrate to convert to, e.g. "320K", or "best"
+	// (defaults to 192K). Lossless formats ignore it.
+	AudioQuality string
+
+	// NormalizeLoudness re-encodes downloads to TargetLUFS integrated
+	// loudness (defaults to -14), so tracks play at an even volume
+	NormalizeLoudness bool
+	TargetLUFS        float64
+
+	// TrimSilence cuts silence below SilenceThresholdDB (defaults to -50)
+	// from the start and end of downloads, re-encoding the audio
+	TrimSilence        bool
+	SilenceThresholdDB float64
+
+	// WriteThumbnail keeps the cover art as a JPEG file next to the audio
+	WriteThumbnail bool
+
+	// LiveMode controls whether search prefers or avoids live versions
+	LiveMode LiveMode
+
+	// PreferClean favors search results titled as clean versions or radio
+	// edits, and avoids explicit ones
+	PreferClean bool
+
+	// MinMatch rejects search results matching less of the query (0..1)
+	MinMatch float64
+
+	// SponsorBlock lists the SponsorBlock categories to cut out of
+	// downloads, e.g. "music_offtopic,intro,outro" (empty disables it)
+	SponsorBlock string
+
+	// KeepPartial keeps the partial files of a failed or cancelled download
+	// instead of removing them, in a folder of the download path where the
+	// next run resumes them
+	KeepPartial bool
+
+	// CookiesFile and CookiesFromBrowser sign yt-dlp in, e.g. for
+	// age-restricted videos
+	CookiesFile        string
+	CookiesFromBrowser string
+
+	// Proxy, if set, is used for every yt-dlp request and cover art
+	// download, e.g. http://proxy:3128. Downloads go through Proxies
+	// instead if set.
+	// Without either, yt-dlp uses the HTTP_PROXY and HTTPS_PROXY variables.
+	Proxy string
+
+	// Proxies, if set, are rotated through per download
+	Proxies *ProxyPool
+
+	// MaxRetries is how many times a download failing with a network error
+	// is retried, waiting longer each time (defaults to 3, 0 disables)
+	MaxRetries int
+
+	// DurationTolerance rejects search results whose duration differs from
+	// the hinted one by more than this fraction (0 disables the check)
+	DurationTolerance float64
+
+	// ArchiveFile, if set, is a yt-dlp download archive: the IDs of videos
+	// downloaded are added to it, and videos listed are not downloaded again
+	// (ErrArchived). yt-dlp appends to it, so concurrent downloads can share it.
+	ArchiveFile string
+
+	// Debug, if set, gets the yt-dlp commands run and their output
+	Debug io.Writer
+
+	// SearchCount is how many YouTube results are compared per search
+	// (defaults to 5)
+	SearchCount int
+
+	// StableNames names files by video ID so repeated runs are idempotent
+	StableNames bool
+
+	// ASCIINames transliterates filenames to ASCII (tags keep the original)
+	ASCIINames bool
+
+	// SpaceReplacement replaces spaces in filenames, if set (e.g. "_")
+	SpaceReplacement string
+
+	// ID3Version is the ID3 tag version of MP3s: "2.3" (the default),
+	// "2.4", or "1" for ID3v2.3 plus an ID3v1 tag for old players
+	ID3Version string
 }
 
 // DownloadResult contains the result of a download
@@ -26,86 +123,405 @@ type DownloadResult struct {
 	Artist     string
 	Duration   int // seconds
 	YouTubeURL string
+
+	ThumbnailPath string // Sidecar cover image, if WriteThumbnail is set
+	Skipped       bool   // File was already there, see StableNames and Place
+	Trimmed       int    // Seconds removed by SponsorBlock
+
+	Resumed         bool // Continued from a partial file of an earlier run
+	ResumeDiscarded bool // The resumed file was broken and downloaded again
+
+	// Uploaded is when the video was uploaded, if known
+	Uploaded time.Time
+
+	// Music is YouTube's music metadata, set for e.g. "Topic" channel uploads
+	Music Metadata
+
+	// Match is the search result that was downloaded, if it was searched for
+	Match *Candidate
+
+	// BPM and Key (e.g. "Am") are the song's tempo and key, from Spotify or
+	// detected in the file, if known
+	BPM float64
+	Key string
+
+	// Analysis is what detection found in the file, with its confidence, if
+	// it was analyzed
+	Analysis *Analysis
 }
 
-// ProgressCallback is called with download progress updates
-type ProgressCallback func(progress float64, status string)
+// infoPrefix marks the JSON video info line printed after download
+const infoPrefix = "dj-info:"
+
+// videoInfo is the subset of yt-dlp's info dict printed after download
+type videoInfo struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	Duration float64 `json:"duration"`
+
+	// Upload date as YYYYMMDD
+	UploadDate string `json:"upload_date"`
+
+	// Music metadata, only present for music content
+	Artist      string `json:"artist"`
+	Track       string `json:"track"`
+	Album       string `json:"album"`
+	ReleaseYear int    `json:"release_year"`
+}
 
 // New creates a new Downloader
 func New(downloadPath string) (*Downloader, error) {
+	return NewWithTools(downloadPath, Tools{})
+}
+
+// Tools overrides the commands used to run yt-dlp and ffmpeg. Each is a
+// program path or name, optionally followed by arguments to run it with
+// (e.g. "python3 -m yt_dlp"). Empty fields are looked up in PATH.
+type Tools struct {
+	YtDlp  string
+	FFmpeg string
+}
+
+// NewWithTools creates a Downloader using the given tool commands
+func NewWithTools(downloadPath string, tools Tools) (*Downloader, error) {
 	// Ensure download path exists
 	if err := os.MkdirAll(downloadPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create download path: %w", err)
 	}
 
 	// Find yt-dlp
-	ytdlpPath, err := exec.LookPath("yt-dlp")
+	ytdlpCmd, err := findTool(tools.YtDlp, "yt-dlp")
 	if err != nil {
-		return nil, fmt.Errorf("yt-dlp not found in PATH: %w", err)
+		return nil, err
 	}
 
 	// Find ffmpeg
-	ffmpegPath, err := exec.LookPath("ffmpeg")
+	ffmpegCmd, err := findTool(tools.FFmpeg, "ffmpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Downloader{
+		downloadPath:       downloadPath,
+		ytdlpCmd:           ytdlpCmd,
+		ffmpegCmd:          ffmpegCmd,
+		staging:            &staging{dirs: make(map[string]string)},
+		MaxRetries:         defaultMaxRetries,
+		TargetLUFS:         defaultTargetLUFS,
+		SilenceThresholdDB: defaultSilenceThresholdDB,
+	}
+
+	// A tool that's found but doesn't run should fail here, not on the
+	// first download
+	if err := d.toolVersions(context.Background()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// findTool resolves a tool command, falling back to name in PATH
+func findTool(command, name string) ([]string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		fields = []string{name}
+	}
+
+	path, err := exec.LookPath(fields[0])
 	if err != nil {
-		return nil, fmt.Errorf("ffmpeg not found in PATH: %w", err)
+		return nil, fmt.Errorf("%s %w (%s): %w", name, ErrToolNotFound, fields[0], err)
+	}
+	fields[0] = path
+	return fields, nil
+}
+
+// ytdlp returns a yt-dlp command with the given args
+func (d *Downloader) ytdlp(ctx context.Context, args ...string) *exec.Cmd {
+	// A later --proxy, e.g. of a download through Proxies, overrides this one
+	if d.Proxy != "" {
+		args = append([]string{"--proxy", d.Proxy}, args...)
+	}
+	cmd := toolCommand(ctx, d.ytdlpCmd, args)
+	if d.Debug != nil {
+		fmt.Fprintf(d.Debug, "$ %s\n", strings.Join(cmd.Args, " "))
+	}
+	return cmd
+}
+
+// ffmpeg returns an ffmpeg command with the given args
+func (d *Downloader) ffmpeg(ctx context.Context, args ...string) *exec.Cmd {
+	return toolCommand(ctx, d.ffmpegCmd, args)
+}
+
+// toolCommand returns a command running tool, with its leading args, plus args
+func toolCommand(ctx context.Context, tool, args []string) *exec.Cmd {
+	full := append(append([]string{}, tool[1:]...), args...)
+	return exec.CommandContext(ctx, tool[0], full...)
+}
+
+// destinationRegex matches the files yt-dlp announces it's writing, e.g.
+// "[download] Destination: song.webm"
+var destinationRegex = regexp.MustCompile(`^\[\w+\] Destination: (.+)$`)
+
+// partialFiles returns the files left behind by an interrupted download
+// to the given destinations
+func partialFiles(destinations []string) []string {
+	var files []string
+	for _, dest := range destinations {
+		for _, path := range []string{dest, dest + ".part", dest + ".ytdl"} {
+			if _, err := os.Stat(path); err == nil {
+				files = append(files, path)
+			}
+		}
 	}
+	return files
+}
 
-	return &Downloader{
-		downloadPath: downloadPath,
-		ytdlpPath:    ytdlpPath,
-		ffmpegPath:   ffmpegPath,
-	}, nil
+// cookieArgs returns the yt-dlp arguments for the configured cookies
+func (d *Downloader) cookieArgs() []string {
+	var args []string
+	if d.CookiesFile != "" {
+		args = append(args, "--cookies", d.CookiesFile)
+	}
+	if d.CookiesFromBrowser != "" {
+		args = append(args, "--cookies-from-browser", d.CookiesFromBrowser)
+	}
+	return args
 }
 
-// SearchAndDownload searches YouTube and downloads the first result
+// isOutputPath reports whether a line of yt-dlp output is the final file
+// path in dir printed by --print after_move:filepath
+func isOutputPath(line, dir, ext string) bool {
+	return strings.HasPrefix(line, dir) && strings.HasSuffix(line, ext)
+}
+
+// downloadOutput collects what yt-dlp reports about a download from its
+// output lines, which come from both of its streams at once
+type downloadOutput struct {
+	stageDir string
+	ext      string
+	debug    io.Writer        // Receives the lines, if set
+	callback ProgressCallback // Receives progress, if set
+
+	mu           sync.Mutex
+	filePath     string    // Printed by --print after_move:filepath
+	info         videoInfo // Printed after infoPrefix
+	resumed      bool
+	archived     bool
+	lines        []string // Everything else, to explain a failure
+	destinations []string // Files being written, for KeepPartial
+}
+
+// handleLine takes in a line of yt-dlp output, from either stream
+func (o *downloadOutput) handleLine(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if path := strings.TrimSpace(line); isOutputPath(path, o.stageDir, o.ext) {
+		o.filePath = path
+		return
+	}
+	if data, ok := strings.CutPrefix(strings.TrimSpace(line), infoPrefix); ok {
+		json.Unmarshal([]byte(data), &o.info)
+		return
+	}
+	if strings.Contains(line, "Resuming download") {
+		o.resumed = true
+	}
+	if strings.Contains(line, "has already been recorded in the archive") {
+		o.archived = true
+	}
+	if matches := destinationRegex.FindStringSubmatch(line); len(matches) > 1 {
+		o.destinations = append(o.destinations, strings.TrimSpace(matches[1]))
+	}
+
+	o.lines = append(o.lines, line)
+	if o.debug != nil {
+		fmt.Fprintln(o.debug, line)
+	}
+	if progress, ok := parseLine(line); ok && o.callback != nil {
+		o.callback(progress)
+	}
+}
+
+// audioExt returns the extension of downloaded files, e.g. ".mp3"
+func (d *Downloader) audioExt() string {
+	if d.AudioFormat == "" {
+		return ".mp3"
+	}
+	return "." + d.AudioFormat
+}
+
+// audioQuality returns the --audio-quality argument for yt-dlp
+func (d *Downloader) audioQuality() string {
+	switch d.AudioQuality {
+	case "":
+		return "192K"
+	case "best":
+		return "0" // Best VBR quality
+	}
+	return d.AudioQuality
+}
+
+// WithDownloadPath returns a copy of the downloader that saves into another directory
+func (d *Downloader) WithDownloadPath(downloadPath string) (*Downloader, error) {
+	if err := os.MkdirAll(downloadPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download path: %w", err)
+	}
+
+	clone := *d
+	clone.downloadPath = downloadPath
+	return &clone, nil
+}
+
+// SearchAndDownload searches YouTube and downloads the best result
 func (d *Downloader) SearchAndDownload(ctx context.Context, query string, callback ProgressCallback) (*DownloadResult, error) {
+	return d.SearchAndDownloadWithHint(ctx, query, SearchHint{}, callback)
+}
+
+// SearchAndDownloadWithHint is like SearchAndDownload, using the hint to
+// reject wrong matches
+func (d *Downloader) SearchAndDownloadWithHint(ctx context.Context, query string, hint SearchHint, callback ProgressCallback) (*DownloadResult, error) {
 	if callback != nil {
-		callback(0, "Searching YouTube...")
+		callback(Progress{Status: "Searching YouTube..."})
 	}
 
 	// Search for the video
-	videoURL, title, err := d.searchYouTube(ctx, query)
+	match, err := d.ResolveWithHint(ctx, query, hint)
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	if callback != nil {
-		callback(10, fmt.Sprintf("Found: %s", title))
+		callback(Progress{Percent: 10, Status: fmt.Sprintf("Found: %s", match.Title)})
 	}
 
 	// Download the video
-	return d.Download(ctx, videoURL, callback)
+	result, err := d.Download(ctx, match.URL, callback)
+	if err != nil {
+		return nil, err
+	}
+	result.Match = match
+	return result, nil
 }
 
-// Download downloads audio from a YouTube URL
+// Download downloads audio from a YouTube URL. A download resumed from a
+// partial file is verified, and downloaded again from scratch if it's broken.
 func (d *Downloader) Download(ctx context.Context, url string, callback ProgressCallback) (*DownloadResult, error) {
+	result, err := d.downloadWithRetry(ctx, url, callback, false)
+	if err != nil || !result.Resumed {
+		return result, err
+	}
+	if err := d.Verify(ctx, result.FilePath); err == nil {
+		return result, nil
+	}
+
+	// The source may have changed since the partial file was written
+	os.RemoveAll(filepath.Dir(result.FilePath))
+	result, err = d.downloadWithRetry(ctx, url, callback, true)
+	if err != nil {
+		return nil, err
+	}
+	result.ResumeDiscarded = true
+	return result, nil
+}
+
+// download runs yt-dlp for a single video into its own staging folder, see
+// Place, through proxy if set. With fresh set, partial files from earlier
+// attempts are ignored.
+func (d *Downloader) download(ctx context.Context, url, proxy string, callback ProgressCallback, fresh bool) (*DownloadResult, error) {
 	if callback != nil {
-		callback(15, "Starting download...")
+		callback(Progress{Percent: downloadStart, Status: "Starting download..."})
 	}
 
-	// Create a unique filename based on video ID
-	outputTemplate := filepath.Join(d.downloadPath, "%(title)s.%(ext)s")
+	// Name files by title, or by video ID for idempotent runs
+	outputTemplate := "%(title)s.%(ext)s"
+	if d.StableNames {
+		if result := d.existingDownload(url); result != nil {
+			if callback != nil {
+				callback(Progress{Percent: 100, Status: "Already downloaded"})
+			}
+			return result, nil
+		}
+		outputTemplate = "%(id)s.%(ext)s"
+	}
+
+	// Each download gets a folder of its own, so concurrent runs can't pick
+	// up or overwrite each other's files
+	stageDir, err := d.stagingDir()
+	if err != nil {
+		return nil, err
+	}
+	// A failed or cancelled download leaves nothing behind. Only its own
+	// folder goes, so the other downloads of -jobs keep their files.
+	finished := false
+	defer func() {
+		if !finished {
+			os.RemoveAll(stageDir)
+		}
+	}()
 
 	// yt-dlp command for downloading audio
 	args := []string{
 		"-f", "bestaudio[ext=m4a]/bestaudio/best",
-		"-x",                    // Extract audio
-		"--audio-format", "mp3", // Convert to MP3
-		"--audio-quality", "192K", // 192kbps
+		"-x", // Extract audio
+		"--audio-format", strings.TrimPrefix(d.audioExt(), "."),
+		"--audio-quality", d.audioQuality(),
 		"--embed-thumbnail", // Embed thumbnail as cover art
 		"--add-metadata",    // Add metadata
 		"--no-playlist",     // Don't download playlists
 		"--no-warnings",
 		"--progress",
 		"--newline", // Progress on new lines
+		"-P", "home:" + stageDir,
 		"-o", outputTemplate,
 		"--print", "after_move:filepath", // Print final file path
+		"--print", "after_move:" + infoPrefix + "%(.{id,title,duration,upload_date,artist,track,album,release_year})j",
 		"--extractor-args", "youtube:player_client=android,web", // Use alternative clients to avoid 403
 		"--user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		url,
 	}
 
-	cmd := exec.CommandContext(ctx, d.ytdlpPath, args...)
+	// Embed the thumbnail as JPEG, cropped square like the covers of
+	// embedImage. yt-dlp splits the arguments like a shell.
+	args = append(args, "--convert-thumbnails", "jpg", "--ppa", `ThumbnailsConvertor:-q:v 2 -vf "`+squareCrop+`"`)
+	if d.WriteThumbnail {
+		// Keep the thumbnail after embedding
+		args = append(args, "--write-thumbnail")
+	}
+
+	if d.SponsorBlock != "" {
+		args = append(args, "--sponsorblock-remove", d.SponsorBlock)
+	}
+
+	if d.ArchiveFile != "" {
+		args = append(args, "--download-archive", d.ArchiveFile)
+	}
+
+	if d.KeepPartial {
+		// Partial files outlive the staging folder, to be resumed next run
+		partialDir, err := filepath.Abs(filepath.Join(d.downloadPath, partialFolder))
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-P", "temp:"+partialDir)
+	}
+
+	if fresh {
+		args = append(args, "--no-continue")
+	}
+
+	if proxy != "" {
+		args = append(args, "--proxy", proxy)
+	}
+
+	// Have yt-dlp's ffmpeg steps write the same tag version as ours
+	if d.audioExt() == ".mp3" {
+		args = append(args, "--postprocessor-args", "ffmpeg_o:"+strings.Join(d.id3Args(), " "))
+	}
+
+	args = append(args, d.cookieArgs()...)
+	args = append(args, url)
+
+	cmd := d.ytdlp(ctx, args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -121,132 +537,129 @@ func (d *Downloader) Download(ctx context.Context, url string, callback Progress
 		return nil, fmt.Errorf("failed to start yt-dlp: %w", err)
 	}
 
-	// Parse progress from stderr
-	var lastFilePath string
-	var stderrLines []string
-	// Match: 45.2% of 5.23MiB at 1.23MiB/s
-	progressRegex := regexp.MustCompile(`(\d+\.?\d*)%`)
-	speedRegex := regexp.MustCompile(`at\s+(\d+\.?\d*\s*[KMG]?i?B/s)`)
-
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			stderrLines = append(stderrLines, line)
-			if matches := progressRegex.FindStringSubmatch(line); len(matches) > 1 {
-				if progress, err := strconv.ParseFloat(matches[1], 64); err == nil {
-					// Scale progress: 15-90% for download
-					scaledProgress := 15 + (progress * 0.75)
-					// Extract speed if available
-					status := "Downloading..."
-					if speedMatches := speedRegex.FindStringSubmatch(line); len(speedMatches) > 1 {
-						status = speedMatches[1]
-					}
-					if callback != nil {
-						callback(scaledProgress, status)
-					}
-				}
+	// yt-dlp versions and configs differ in which stream gets progress and
+	// --print output, so both streams are scanned for both
+	out := &downloadOutput{stageDir: stageDir, ext: d.audioExt(), debug: d.Debug, callback: callback}
+	var wg sync.WaitGroup
+	for _, stream := range []io.Reader{stdout, stderr} {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				out.handleLine(scanner.Text())
 			}
-		}
-	}()
-
-	// Read final file path from stdout
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && strings.HasSuffix(line, ".mp3") {
-			lastFilePath = line
-		}
+		}(stream)
 	}
 
+	// Pipes must be fully read before Wait closes them
+	wg.Wait()
+
 	if err := cmd.Wait(); err != nil {
-		// Include stderr output in error message
-		errMsg := "yt-dlp failed"
-		if len(stderrLines) > 0 {
-			// Get last few error lines
-			start := len(stderrLines) - 3
-			if start < 0 {
-				start = 0
-			}
-			errMsg = fmt.Sprintf("yt-dlp failed: %s", strings.Join(stderrLines[start:], "; "))
+		dlErr := newDownloadError(url, out.lines, err)
+		if d.KeepPartial {
+			dlErr.PartialFiles = partialFiles(out.destinations)
 		}
-		return nil, fmt.Errorf("%s: %w", errMsg, err)
+		return nil, dlErr
+	}
+
+	lastFilePath, info := out.filePath, out.info
+	if out.archived && lastFilePath == "" {
+		return nil, fmt.Errorf("%w: %s", ErrArchived, url)
 	}
 
 	if lastFilePath == "" {
-		// Try to find the downloaded file
-		files, err := filepath.Glob(filepath.Join(d.downloadPath, "*.mp3"))
+		// Try to find the downloaded file, the only one in its folder
+		files, err := filepath.Glob(filepath.Join(stageDir, "*"+d.audioExt()))
 		if err != nil || len(files) == 0 {
 			return nil, fmt.Errorf("download completed but file not found")
 		}
 		lastFilePath = files[len(files)-1]
 	}
 
-	if callback != nil {
-		callback(100, "Download complete!")
+	lastFilePath, err = d.normalizeExtension(ctx, lastFilePath)
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract title from filename
-	title := strings.TrimSuffix(filepath.Base(lastFilePath), ".mp3")
-
-	return &DownloadResult{
-		FilePath:   lastFilePath,
-		Title:      title,
-		YouTubeURL: url,
-	}, nil
-}
-
-// searchYouTube searches YouTube and returns the URL and title of the first result
-func (d *Downloader) searchYouTube(ctx context.Context, query string) (url string, title string, err error) {
-	// Use yt-dlp to search YouTube
-	args := []string{
-		"ytsearch1:" + query,
-		"--get-url",
-		"--get-title",
-		"--no-warnings",
-		"--no-playlist",
+	lastFilePath, err = d.renameOutput(lastFilePath, info.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.CommandContext(ctx, d.ytdlpPath, args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", "", fmt.Errorf("search failed: %w", err)
+	// Trimming and normalizing share one re-encode, so quality is only
+	// lost once
+	var filters []string
+	status := ""
+	if d.TrimSilence {
+		filters = append(filters, d.silenceFilter())
+		status = "Trimming silence..."
+	}
+	if d.NormalizeLoudness {
+		// After trimming, so silence doesn't count toward the loudness
+		filters = append(filters, d.loudnessFilter())
+		if status == "" {
+			status = "Normalizing loudness..."
+		}
+	}
+	if len(filters) > 0 {
+		if callback != nil {
+			callback(Progress{Percent: postprocessStart, Status: status})
+		}
+		if err := d.filterAudio(ctx, lastFilePath, filters); err != nil {
+			return nil, fmt.Errorf("failed to process audio: %w", err)
+		}
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
-		return "", "", fmt.Errorf("no results found for: %s", query)
+	if callback != nil {
+		callback(Progress{Percent: 100, Status: "Download complete!"})
 	}
 
-	title = strings.TrimSpace(lines[0])
-	url = strings.TrimSpace(lines[1])
+	// Prefer the video title, falling back to the filename
+	title := info.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(lastFilePath), filepath.Ext(lastFilePath))
+	}
 
-	// The URL from --get-url is the direct stream URL, we need the watch URL
-	// So let's get the video ID instead
-	args = []string{
-		"ytsearch1:" + query,
-		"--get-id",
-		"--get-title",
-		"--no-warnings",
-		"--no-playlist",
+	result := &DownloadResult{
+		FilePath:   lastFilePath,
+		Title:      title,
+		Artist:     info.Artist,
+		Duration:   int(info.Duration),
+		YouTubeURL: url,
+		Resumed:    out.resumed,
+		Music: Metadata{
+			Artist: info.Artist,
+			Title:  info.Track,
+			Album:  info.Album,
+			Year:   info.ReleaseYear,
+		},
 	}
 
-	cmd = exec.CommandContext(ctx, d.ytdlpPath, args...)
-	output, err = cmd.Output()
-	if err != nil {
-		return "", "", fmt.Errorf("search failed: %w", err)
+	if uploaded, err := time.Parse("20060102", info.UploadDate); err == nil {
+		result.Uploaded = uploaded
 	}
 
-	lines = strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
-		return "", "", fmt.Errorf("no results found for: %s", query)
+	// The video's duration is from before any cuts. Transcoding alone can
+	// shift it by a second or so.
+	if d.SponsorBlock != "" {
+		if duration, err := d.probeDuration(ctx, lastFilePath); err == nil && result.Duration > 0 {
+			if cut := result.Duration - int(duration.Seconds()); cut > 2 {
+				result.Trimmed = cut
+				result.Duration -= cut
+			}
+		}
 	}
 
-	title = strings.TrimSpace(lines[0])
-	videoID := strings.TrimSpace(lines[1])
-	url = "https://www.youtube.com/watch?v=" + videoID
+	if d.WriteThumbnail {
+		thumbPath := strings.TrimSuffix(lastFilePath, filepath.Ext(lastFilePath)) + ".jpg"
+		if _, err := os.Stat(thumbPath); err == nil {
+			result.ThumbnailPath = thumbPath
+		}
+	}
 
-	return url, title, nil
+	finished = true
+	return result, nil
 }
 
 // GetVideoInfo gets information about a YouTube video without downloading
@@ -258,8 +671,9 @@ func (d *Downloader) GetVideoInfo(ctx context.Context, url string) (title, artis
 		"--no-warnings",
 		"--no-playlist",
 	}
+	args = append(args, d.cookieArgs()...)
 
-	cmd := exec.CommandContext(ctx, d.ytdlpPath, args...)
+	cmd := d.ytdlp(ctx, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", "", 0, fmt.Errorf("failed to get video info: %w", err)
@@ -0,0 +1,113 @@
+package downloader
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIsOutputPath(t *testing.T) {
+	const dir = "/music/.dj-staging-123/456"
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{dir + "/Around The World.mp3", true},
+		{dir + "/K0HSD_i2DvA.mp3", true},
+		{dir + "/Around The World.webm", false}, // Before extraction
+		{dir + "/Around The World.mp3.part", false},
+		{"/music/Around The World.mp3", false}, // Outside the staging folder
+		{"[download] Destination: " + dir + "/Around The World.mp3", false},
+		{"[download]  42.0% of    3.52MiB at    1.21MiB/s ETA 00:02", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isOutputPath(tt.line, dir, ".mp3"); got != tt.want {
+			t.Errorf("isOutputPath(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadOutputHandleLine(t *testing.T) {
+	const dir = "/music/.dj-staging-123/456"
+	var (
+		progress = []string{
+			"[download] Destination: " + dir + "/Around The World.webm",
+			"[download]  42.0% of    3.52MiB at    1.21MiB/s ETA 00:02",
+			"[download] 100% of    3.52MiB in 00:00:03 at 1.17MiB/s",
+			"[ExtractAudio] Destination: " + dir + "/Around The World.mp3",
+		}
+		printed = []string{
+			dir + "/Around The World.mp3",
+			`dj-info:{"id":"K0HSD_i2DvA","title":"Around The World","duration":429,"artist":"Daft Punk"}`,
+		}
+	)
+
+	tests := []struct {
+		name   string
+		stdout []string
+		stderr []string
+	}{
+		{"progress on stdout, prints on stderr", progress, printed},
+		{"prints on stdout, progress on stderr", printed, progress},
+		{"everything on stdout", append(append([]string{}, progress...), printed...), nil},
+		{"everything on stderr", nil, append(append([]string{}, progress...), printed...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var updates []Progress
+			out := &downloadOutput{stageDir: dir, ext: ".mp3", callback: func(p Progress) {
+				mu.Lock()
+				updates = append(updates, p)
+				mu.Unlock()
+			}}
+
+			// Both streams are read at once, as in download
+			var wg sync.WaitGroup
+			for _, stream := range [][]string{tt.stdout, tt.stderr} {
+				wg.Add(1)
+				go func(lines []string) {
+					defer wg.Done()
+					for _, line := range lines {
+						out.handleLine(line)
+					}
+				}(stream)
+			}
+			wg.Wait()
+
+			if want := dir + "/Around The World.mp3"; out.filePath != want {
+				t.Errorf("filePath = %q, want %q", out.filePath, want)
+			}
+			if out.info.ID != "K0HSD_i2DvA" || out.info.Title != "Around The World" || out.info.Duration != 429 {
+				t.Errorf("info = %+v, want the printed video info", out.info)
+			}
+			if len(updates) != 3 {
+				t.Errorf("got %d progress updates, want 3: %+v", len(updates), updates)
+			}
+			if len(out.destinations) != 2 {
+				t.Errorf("destinations = %q, want the download and the extracted audio", out.destinations)
+			}
+			for _, line := range out.lines {
+				if line == printed[0] || line == printed[1] {
+					t.Errorf("printed line %q kept as output", line)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadOutputResumedAndArchived(t *testing.T) {
+	out := &downloadOutput{stageDir: "/music/.dj-staging-123/456", ext: ".mp3"}
+	out.handleLine("[download] Resuming download at byte 1048576")
+	out.handleLine("[download] K0HSD_i2DvA: has already been recorded in the archive")
+	if !out.resumed {
+		t.Error("resumed = false, want true")
+	}
+	if !out.archived {
+		t.Error("archived = false, want true")
+	}
+	if len(out.lines) != 2 {
+		t.Errorf("lines = %q, want both lines kept", out.lines)
+	}
+}
@@ -0,0 +1,188 @@
+package downloader
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// ErrorKind classifies why a download failed
+type ErrorKind int
+
+const (
+	// KindUnknown is a failure with no more specific kind
+	KindUnknown ErrorKind = iota
+	// KindAgeRestricted means YouTube requires a signed-in adult account
+	KindAgeRestricted
+	// KindSponsorBlock means cutting SponsorBlock segments failed
+	KindSponsorBlock
+	// KindProxy means yt-dlp couldn't connect through the proxy
+	KindProxy
+	// KindBotCheck means YouTube asked to sign in to prove it's not a bot
+	KindBotCheck
+	// KindUnavailable means the video is private, removed or blocked
+	KindUnavailable
+	// KindTransient is a network error or HTTP error likely to pass on retry
+	KindTransient
+	// KindGeoBlocked means the video isn't available in this country
+	KindGeoBlocked
+)
+
+// String names the kind, e.g. "bot-check"
+func (k ErrorKind) String() string {
+	switch k {
+	case KindAgeRestricted:
+		return "age-restricted"
+	case KindSponsorBlock:
+		return "sponsorblock"
+	case KindProxy:
+		return "proxy"
+	case KindBotCheck:
+		return "bot-check"
+	case KindUnavailable:
+		return "unavailable"
+	case KindTransient:
+		return "network"
+	case KindGeoBlocked:
+		return "geo-blocked"
+	}
+	return "other"
+}
+
+// ErrToolNotFound is returned when yt-dlp or ffmpeg isn't installed
+var ErrToolNotFound = errors.New("not found")
+
+// ErrArchived is returned for a video in the ArchiveFile, which isn't
+// downloaded again
+var ErrArchived = errors.New("already in the download archive")
+
+// ErrExists is returned by Place when the download path already has a file
+// of the download's name, which is left as it is
+var ErrExists = errors.New("a file of that name already exists")
+
+// Errors a DownloadError of the matching kind is, for errors.Is
+var (
+	ErrAgeRestricted    = errors.New("age-restricted video")
+	ErrBotCheck         = errors.New("YouTube bot check")
+	ErrVideoUnavailable = errors.New("video unavailable")
+	ErrGeoBlocked       = errors.New("video not available in this country")
+)
+
+// kindErrors maps error kinds to their errors
+var kindErrors = map[ErrorKind]error{
+	KindAgeRestricted: ErrAgeRestricted,
+	KindBotCheck:      ErrBotCheck,
+	KindUnavailable:   ErrVideoUnavailable,
+	KindGeoBlocked:    ErrGeoBlocked,
+}
+
+// ageRestrictedRegex matches yt-dlp's messages for age-gated videos
+var ageRestrictedRegex = regexp.MustCompile(`(?i)confirm your age|age[- ]restricted|inappropriate for some users`)
+
+// sponsorBlockRegex matches yt-dlp's errors and warnings for SponsorBlock
+// failures, but not its [SponsorBlock] and [ModifyChapters] progress lines
+var sponsorBlockRegex = regexp.MustCompile(`(?i)^(ERROR|WARNING):.*(sponsorblock|ModifyChapters)`)
+
+// proxyErrorRegex matches yt-dlp's messages for unreachable proxies
+var proxyErrorRegex = regexp.MustCompile(`(?i)unable to connect to proxy|cannot connect to proxy|ProxyError|tunnel connection failed|socks\w*error`)
+
+// botCheckRegex matches yt-dlp's messages for YouTube's bot check
+var botCheckRegex = regexp.MustCompile(`(?i)confirm you.re not a bot`)
+
+// unavailableRegex matches yt-dlp's messages for videos that can't be watched
+var unavailableRegex = regexp.MustCompile(`(?i)video unavailable|private video|has been removed|(is not|no longer) available`)
+
+// geoBlockedRegex matches yt-dlp's messages for videos blocked in this country
+var geoBlockedRegex = regexp.MustCompile(`(?i)available in your country|blocked it in your country|geo.?restrict`)
+
+// transientRegex matches yt-dlp's messages for errors worth retrying
+var transientRegex = regexp.MustCompile(`(?i)HTTP Error (403|429|5\d\d)|timed out|connection (reset|aborted|refused)|temporary failure in name resolution|IncompleteRead|network is unreachable`)
+
+// DownloadError is returned when yt-dlp fails to download a video or to
+// search. errors.Is matches it to the Err variable of its kind, e.g.
+// ErrVideoUnavailable.
+type DownloadError struct {
+	Kind     ErrorKind
+	URL      string // The video, or the search
+	Output   string // Last lines of yt-dlp's output
+	ExitCode int    // yt-dlp's exit code, -1 if it didn't exit on its own
+	Err      error
+
+	// PartialFiles are the incomplete files kept with KeepPartial
+	PartialFiles []string
+}
+
+func (e *DownloadError) Error() string {
+	if e.Output == "" {
+		return "yt-dlp failed: " + e.Err.Error()
+	}
+	return "yt-dlp failed: " + e.Output + ": " + e.Err.Error()
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DownloadError) Is(target error) bool {
+	return target != nil && kindErrors[e.Kind] == target
+}
+
+// IsAgeRestricted reports whether err is a download blocked by an age gate
+func IsAgeRestricted(err error) bool {
+	var dlErr *DownloadError
+	return errors.As(err, &dlErr) && dlErr.Kind == KindAgeRestricted
+}
+
+// IsProxyError reports whether err is a download that failed to connect
+// through its proxy
+func IsProxyError(err error) bool {
+	var dlErr *DownloadError
+	return errors.As(err, &dlErr) && dlErr.Kind == KindProxy
+}
+
+// newDownloadError classifies a failed download from yt-dlp's output lines
+func newDownloadError(url string, outputLines []string, err error) *DownloadError {
+	kind := KindUnknown
+	for _, line := range outputLines {
+		if ageRestrictedRegex.MatchString(line) {
+			kind = KindAgeRestricted
+			break
+		}
+		switch {
+		case kind != KindUnknown && kind != KindTransient:
+		case sponsorBlockRegex.MatchString(line):
+			kind = KindSponsorBlock
+		case botCheckRegex.MatchString(line):
+			kind = KindBotCheck
+		case proxyErrorRegex.MatchString(line):
+			kind = KindProxy
+		case geoBlockedRegex.MatchString(line):
+			kind = KindGeoBlocked
+		case unavailableRegex.MatchString(line):
+			kind = KindUnavailable
+		case transientRegex.MatchString(line):
+			kind = KindTransient
+		}
+	}
+
+	// Keep the last few lines, where yt-dlp reports the error
+	start := len(outputLines) - 3
+	if start < 0 {
+		start = 0
+	}
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	return &DownloadError{
+		Kind:     kind,
+		URL:      url,
+		Output:   strings.Join(outputLines[start:], "; "),
+		ExitCode: exitCode,
+		Err:      err,
+	}
+}
@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNewDownloadErrorKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr []string
+		want   ErrorKind
+	}{
+		{
+			name:   "age restricted",
+			stderr: []string{"ERROR: [youtube] K0HSD_i2DvA: Sign in to confirm your age. This video may be inappropriate for some users."},
+			want:   KindAgeRestricted,
+		},
+		{
+			name:   "bot check",
+			stderr: []string{"ERROR: [youtube] K0HSD_i2DvA: Sign in to confirm you’re not a bot. Use --cookies-from-browser or --cookies for the authentication."},
+			want:   KindBotCheck,
+		},
+		{
+			name:   "unavailable",
+			stderr: []string{"ERROR: [youtube] K0HSD_i2DvA: Video unavailable. This video has been removed by the uploader"},
+			want:   KindUnavailable,
+		},
+		{
+			name:   "private",
+			stderr: []string{"ERROR: [youtube] K0HSD_i2DvA: Private video. Sign in if you've been granted access to this video"},
+			want:   KindUnavailable,
+		},
+		{
+			name:   "geo blocked",
+			stderr: []string{"ERROR: [youtube] K0HSD_i2DvA: The uploader has not made this video available in your country"},
+			want:   KindGeoBlocked,
+		},
+		{
+			name:   "proxy",
+			stderr: []string{"ERROR: Unable to download webpage: ('Unable to connect to proxy', OSError('Tunnel connection failed: 407 Proxy Authentication Required'))"},
+			want:   KindProxy,
+		},
+		{
+			name:   "forbidden",
+			stderr: []string{"ERROR: unable to download video data: HTTP Error 403: Forbidden"},
+			want:   KindTransient,
+		},
+		{
+			name:   "rate limited",
+			stderr: []string{"ERROR: Unable to download API page: HTTP Error 429: Too Many Requests"},
+			want:   KindTransient,
+		},
+		{
+			name:   "timed out",
+			stderr: []string{"ERROR: Unable to download webpage: The read operation timed out"},
+			want:   KindTransient,
+		},
+		{
+			name:   "sponsorblock",
+			stderr: []string{"ERROR: Postprocessing: Conversion failed! (ModifyChapters)"},
+			want:   KindSponsorBlock,
+		},
+		{
+			name: "sponsorblock progress before a failure",
+			stderr: []string{
+				"[SponsorBlock] Fetching SponsorBlock segments",
+				"[ModifyChapters] Removing chapters from K0HSD_i2DvA.webm",
+				"ERROR: [youtube] K0HSD_i2DvA: Video unavailable",
+			},
+			want: KindUnavailable,
+		},
+		{
+			name: "retried then unavailable",
+			stderr: []string{
+				"WARNING: [youtube] HTTP Error 503: Service Unavailable. Retrying (1/3)...",
+				"ERROR: [youtube] K0HSD_i2DvA: Video unavailable",
+			},
+			want: KindUnavailable,
+		},
+		{
+			name: "unavailable kept over a later warning",
+			stderr: []string{
+				"ERROR: [youtube] K0HSD_i2DvA: Video unavailable",
+				"WARNING: Unable to communicate with SponsorBlock API: HTTP Error 503",
+			},
+			want: KindUnavailable,
+		},
+		{
+			name:   "unknown",
+			stderr: []string{"ERROR: Postprocessing: ffprobe and ffmpeg not found"},
+			want:   KindUnknown,
+		},
+		{
+			name: "no output",
+			want: KindUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newDownloadError("https://www.youtube.com/watch?v=K0HSD_i2DvA", tt.stderr, errors.New("exit status 1"))
+			if err.Kind != tt.want {
+				t.Errorf("Kind = %v, want %v", err.Kind, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadErrorIs(t *testing.T) {
+	for kind, sentinel := range kindErrors {
+		err := error(&DownloadError{Kind: kind, Err: errors.New("exit status 1")})
+		if !errors.Is(err, sentinel) {
+			t.Errorf("errors.Is(%v error, %v) = false", kind, sentinel)
+		}
+		for other, otherSentinel := range kindErrors {
+			if other != kind && errors.Is(err, otherSentinel) {
+				t.Errorf("errors.Is(%v error, %v) = true", kind, otherSentinel)
+			}
+		}
+	}
+	if errors.Is(&DownloadError{Kind: KindUnknown, Err: errors.New("exit status 1")}, ErrVideoUnavailable) {
+		t.Error("unknown error is ErrVideoUnavailable")
+	}
+}
+
+// fakeTool writes a shell script standing in for yt-dlp or ffmpeg and
+// returns its path
+func fakeTool(t *testing.T, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSearchErrorKindWhileDebugging(t *testing.T) {
+	d := &Downloader{
+		ytdlpCmd: []string{fakeTool(t, "yt-dlp", `echo "ERROR: [youtube:search] Sign in to confirm you're not a bot" >&2; exit 1`)},
+	}
+	var debug bytes.Buffer
+	d.Debug = &debug
+
+	_, err := d.searchCandidates(context.Background(), "Daft Punk Around The World", 5)
+	if !errors.Is(err, ErrBotCheck) {
+		t.Errorf("searchCandidates() error = %v, want ErrBotCheck", err)
+	}
+	if !bytes.Contains(debug.Bytes(), []byte("not a bot")) {
+		t.Errorf("yt-dlp's stderr not echoed to Debug: %q", debug.String())
+	}
+}
@@ -0,0 +1,219 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// invalidFilenameChars are rejected by Windows (and "/" everywhere)
+const invalidFilenameChars = `<>:"/\|?*`
+
+// reservedFilenames are device names Windows rejects as names, with any
+// extension
+var reservedFilenames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Accented Latin letters and their ASCII equivalents, rune by rune
+const (
+	accentedLetters   = "ÀÁÂÃÄÅàáâãäåÇçÈÉÊËèéêëÌÍÎÏìíîïÑñÒÓÔÕÖØòóôõöøÙÚÛÜùúûüÝýÿĀāĂăĄąĆćĈĉĊċČčĎďĐđĒēĔĕĖėĘęĚěĜĝĞğĠġĢģĤĥĦħĨĩĪīĬĭĮįİıĴĵĶķĹĺĻļĽľĿŀŁłŃńŅņŇňŌōŎŏŐőŔŕŖŗŘřŚśŜŝŞşŠšŢţŤťŦŧŨũŪūŬŭŮůŰűŲųŴŵŶŷŸŹźŻżŽž"
+	unaccentedLetters = "AAAAAAaaaaaaCcEEEEeeeeIIIIiiiiNnOOOOOOooooooUUUUuuuuYyyAaAaAaCcCcCcCcDdDdEeEeEeEeEeGgGgGgGgHhHhIiIiIiIiIiJjKkLlLlLlLlLlNnNnNnOoOoOoRrRrRrSsSsSsSsTtTtTtUuUuUuUuUuUuWwYyYZzZzZz"
+)
+
+// transliterations maps non-ASCII runes to ASCII text. A table of the
+// letters in Latin-script artist names and titles keeps the downloader free
+// of dependencies beyond the standard library. Unicode decomposition alone
+// wouldn't do: letters like ø, ł, ß and æ don't decompose into ASCII.
+var transliterations = map[rune]string{
+	'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'ß': "ss",
+	'Þ': "Th", 'þ': "th", 'Ð': "D", 'ð': "d",
+	'‘': "'", '’': "'", '“': `"`, '”': `"`, '–': "-", '—': "-", '…': "...",
+}
+
+func init() {
+	unaccented := []rune(unaccentedLetters)
+	for i, r := range []rune(accentedLetters) {
+		transliterations[r] = string(unaccented[i])
+	}
+}
+
+// ASCIIName transliterates a name to ASCII ("Björk" becomes "Bjork").
+// Characters without an equivalent, such as CJK, are dropped.
+func ASCIIName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < unicode.MaxASCII:
+			b.WriteRune(r)
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		}
+	}
+	// Dropped characters can leave a dangling separator ("坂本龍一 - Song")
+	return strings.Trim(strings.Join(strings.Fields(b.String()), " "), " -")
+}
+
+// finalName applies the filename options to a base name (without extension)
+// and sanitizes the result. fallback is used if nothing usable remains.
+// Sanitizing comes last, as transliterating can bring back characters it
+// removes, like the '"' of curly quotes.
+func (d *Downloader) finalName(name, fallback string) string {
+	if d.ASCIINames {
+		name = ASCIIName(name)
+		if strings.Trim(name, " -_.") == "" {
+			name = fallback
+		}
+	}
+	name = SanitizeFilename(name)
+	if d.SpaceReplacement != "" {
+		name = strings.ReplaceAll(name, " ", d.SpaceReplacement)
+	}
+	return name
+}
+
+// Rename gives a downloaded file a new base name (without extension),
+// applying the filename options, and updates the result
+func (d *Downloader) Rename(result *DownloadResult, name string) error {
+	name = d.finalName(name, result.Title)
+	newPath, err := moveOutput(result.FilePath, name)
+	if err != nil {
+		return err
+	}
+
+	result.FilePath = newPath
+	if result.ThumbnailPath != "" {
+		result.ThumbnailPath = strings.TrimSuffix(newPath, filepath.Ext(newPath)) + ".jpg"
+	}
+	return nil
+}
+
+// renameOutput renames a downloaded file according to the filename
+// options, returning the new path. yt-dlp only avoids characters invalid on
+// the system it runs on, so names are sanitized here to stay valid when
+// copied to a Windows or FAT32 drive.
+func (d *Downloader) renameOutput(filePath, fallback string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return moveOutput(filePath, d.finalName(name, fallback))
+}
+
+// moveOutput renames a file (and its thumbnail sidecar, if any) to a new
+// base name in the same directory, returning the new path
+func moveOutput(filePath, name string) (string, error) {
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+	newBase := filepath.Join(filepath.Dir(filePath), name)
+	if newBase == base {
+		return filePath, nil
+	}
+
+	if err := os.Rename(filePath, newBase+ext); err != nil {
+		return "", fmt.Errorf("failed to rename %s: %w", filepath.Base(filePath), err)
+	}
+	if _, err := os.Stat(base + ".jpg"); err == nil {
+		os.Rename(base+".jpg", newBase+".jpg")
+	}
+	return newBase + ext, nil
+}
+
+// containerExtensions maps ffprobe container formats to file extensions
+var containerExtensions = map[string]string{
+	"mp3":                     ".mp3",
+	"mov,mp4,m4a,3gp,3g2,mj2": ".m4a",
+	"matroska,webm":           ".webm",
+	"ogg":                     ".ogg",
+	"flac":                    ".flac",
+	"wav":                     ".wav",
+	"aac":                     ".aac",
+}
+
+// isAudioExtension reports whether ext is one of the extensions dj produces
+func isAudioExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, known := range containerExtensions {
+		if ext == known {
+			return true
+		}
+	}
+	return ext == ".opus"
+}
+
+// IsAudioFile reports whether a path has the extension of an audio format
+// dj can download
+func IsAudioFile(path string) bool {
+	return isAudioExtension(filepath.Ext(path))
+}
+
+// normalizeExtension drops leftover extensions (e.g. "song.m4a.mp3") and
+// makes the extension match the file's actual container, returning the new path
+func (d *Downloader) normalizeExtension(ctx context.Context, filePath string) (string, error) {
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+
+	// Trust the container over the name when ffprobe recognizes it. Opus
+	// comes in an Ogg container.
+	wantExt := strings.ToLower(ext)
+	if format, err := d.probeFormat(ctx, filePath); err == nil {
+		if known, ok := containerExtensions[format]; ok && !(known == ".ogg" && wantExt == ".opus") {
+			wantExt = known
+		}
+	}
+	if wantExt != ext {
+		if err := os.Rename(filePath, base+wantExt); err != nil {
+			return "", fmt.Errorf("failed to rename %s: %w", filepath.Base(filePath), err)
+		}
+		filePath = base + wantExt
+	}
+
+	name := filepath.Base(base)
+	for isAudioExtension(filepath.Ext(name)) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == "" {
+		return filePath, nil
+	}
+	return moveOutput(filePath, name)
+}
+
+// SanitizeFilename makes a string safe to use as a file or folder name
+func SanitizeFilename(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(invalidFilenameChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Windows rejects trailing dots and spaces, and device names like CON
+	cleaned = strings.TrimRight(strings.TrimSpace(cleaned), ". ")
+	if cleaned == "" {
+		return "untitled"
+	}
+	if reservedFilenames[strings.ToUpper(strings.SplitN(cleaned, ".", 2)[0])] {
+		cleaned = "_" + cleaned
+	}
+	return cleaned
+}
+
+// PlannedPath returns where downloading the candidate would save it: under
+// name (as with Rename) if set, else under the name the download would get
+func (d *Downloader) PlannedPath(c *Candidate, name string) string {
+	id := ExtractYouTubeID(c.URL)
+	switch {
+	case d.StableNames:
+		name = id
+	case name != "":
+		name = d.finalName(name, c.Title)
+	default:
+		// yt-dlp swaps slashes in titles for a lookalike
+		name = d.finalName(strings.ReplaceAll(c.Title, "/", "⧸"), id)
+	}
+	return filepath.Join(d.downloadPath, name+d.audioExt())
+}
@@ -0,0 +1,162 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Daft Punk - Around The World", "Daft Punk - Around The World"},
+		{"Artist: Title", "Artist_ Title"},
+		{"12:00 AM", "12_00 AM"},
+		{"AC/DC - Back In Black", "AC_DC - Back In Black"},
+		{`Left\Right`, "Left_Right"},
+		{`What? <Live> "Remix" | *`, `What_ _Live_ _Remix_ _ _`},
+		{"Song 🔥🎶", "Song 🔥🎶"},
+		{"🎧", "🎧"},
+		{"Mr. Brightside...", "Mr. Brightside"},
+		{"Song. . .", "Song"},
+		{"  Spaces around  ", "Spaces around"},
+		{"Tab\there", "Tab_here"},
+		{"CON", "_CON"},
+		{"nul.mp3", "_nul.mp3"},
+		{"CONCERT", "CONCERT"},
+		{"...", "untitled"},
+		{"", "untitled"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestASCIIName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Björk - Jóga", "Bjork - Joga"},
+		{"Sigur Rós - Hoppípolla", "Sigur Ros - Hoppipolla"},
+		{"Beyoncé - Déjà Vu", "Beyonce - Deja Vu"},
+		{"Motörhead", "Motorhead"},
+		{"Mø - Lean On", "Mo - Lean On"},
+		{"Łona i Webber", "Lona i Webber"},
+		{"Straße", "Strasse"},
+		{"Æther – Œuvre", "AEther - OEuvre"},
+		{"Señorita", "Senorita"},
+		{"Don’t Stop “Live”…", `Don't Stop "Live"...`},
+		{"坂本龍一 - Merry Christmas Mr. Lawrence", "Merry Christmas Mr. Lawrence"},
+		{"YOASOBI - 夜に駆ける", "YOASOBI"},
+		{"방탄소년단 (BTS) - Dynamite", "(BTS) - Dynamite"},
+		{"宇多田ヒカル", ""},
+		{"Daft Punk - Around The World", "Daft Punk - Around The World"},
+	}
+	for _, tt := range tests {
+		if got := ASCIIName(tt.name); got != tt.want {
+			t.Errorf("ASCIIName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFinalNameASCIIFallback(t *testing.T) {
+	d := &Downloader{ASCIINames: true}
+	if got := d.finalName("宇多田ヒカル", "K0HSD_i2DvA"); got != "K0HSD_i2DvA" {
+		t.Errorf("finalName() = %q, want the fallback when nothing ASCII is left", got)
+	}
+	if got := d.finalName("Björk", "K0HSD_i2DvA"); got != "Bjork" {
+		t.Errorf("finalName() = %q, want %q", got, "Bjork")
+	}
+}
+
+func TestFinalNameASCIISanitized(t *testing.T) {
+	d := &Downloader{ASCIINames: true}
+	tests := []struct {
+		name     string
+		fallback string
+		want     string
+	}{
+		{"David Bowie - “Heroes”", "K0HSD_i2DvA", "David Bowie - _Heroes_"},
+		{"Wait for It…", "K0HSD_i2DvA", "Wait for It"},
+		{"宇多田ヒカル", "光 / Hikari", "光 _ Hikari"},
+	}
+	for _, tt := range tests {
+		if got := d.finalName(tt.name, tt.fallback); got != tt.want {
+			t.Errorf("finalName(%q, %q) = %q, want %q", tt.name, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeExtension(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		container string // What ffprobe reports, "" if it fails
+		want      string
+	}{
+		{"doubled", "song.m4a.mp3", "mp3", "song.mp3"},
+		{"tripled", "song.webm.m4a.mp3", "mp3", "song.mp3"},
+		{"mismatched", "song.mp3", "mov,mp4,m4a,3gp,3g2,mj2", "song.m4a"},
+		{"mismatched and doubled", "song.mp3.m4a", "matroska,webm", "song.webm"},
+		{"upper case", "song.MP3", "mp3", "song.mp3"},
+		{"opus in ogg", "song.opus", "ogg", "song.opus"},
+		{"dotted title", "Mr. Brightside.mp3", "mp3", "Mr. Brightside.mp3"},
+		{"dotted title, doubled", "Vol. 2.m4a.mp3", "mp3", "Vol. 2.mp3"},
+		{"unknown container", "song.m4a.flac", "aiff", "song.flac"},
+		{"ffprobe fails", "song.m4a.mp3", "", "song.mp3"},
+		{"only extensions", ".m4a.mp3", "mp3", ".m4a.mp3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := "exit 1"
+			if tt.container != "" {
+				script = "echo '" + tt.container + "'"
+			}
+			ffprobe := fakeTool(t, "ffprobe", script)
+			d := &Downloader{ffmpegCmd: []string{filepath.Join(filepath.Dir(ffprobe), "ffmpeg")}}
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := d.normalizeExtension(context.Background(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("normalizeExtension(%q) = %q, want %q", tt.file, filepath.Base(got), tt.want)
+			}
+			if _, err := os.Stat(got); err != nil {
+				t.Errorf("file not at the returned path: %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeExtensionMovesThumbnail(t *testing.T) {
+	ffprobe := fakeTool(t, "ffprobe", "echo mp3")
+	d := &Downloader{ffmpegCmd: []string{filepath.Join(filepath.Dir(ffprobe), "ffmpeg")}}
+
+	dir := t.TempDir()
+	for _, name := range []string{"song.m4a.mp3", "song.m4a.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.normalizeExtension(context.Background(), filepath.Join(dir, "song.m4a.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "song.jpg")); err != nil {
+		t.Errorf("thumbnail not renamed with the file: %v", err)
+	}
+}
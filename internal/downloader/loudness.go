@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// defaultTargetLUFS is the loudness downloads are normalized to by default,
+// the level streaming services play at
+const defaultTargetLUFS = -14.0
+
+// audioEncoders are the ffmpeg encoders for re-encoding each audio format
+var audioEncoders = map[string]string{
+	".mp3":  "libmp3lame",
+	".opus": "libopus",
+	".flac": "flac",
+	".m4a":  "aac",
+	".wav":  "pcm_s16le",
+}
+
+// loudnessFilter is the ffmpeg filter evening out loudness to TargetLUFS
+func (d *Downloader) loudnessFilter() string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", d.TargetLUFS)
+}
+
+// filterAudio re-encodes an audio file through a chain of ffmpeg audio
+// filters in one pass, replacing the file. Cover art and tags are kept.
+func (d *Downloader) filterAudio(ctx context.Context, filePath string, filters []string) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	encoder, ok := audioEncoders[ext]
+	if !ok {
+		return fmt.Errorf("can't re-encode %s files", ext)
+	}
+
+	args := []string{
+		"-y",
+		"-v", "error",
+		"-i", filePath,
+		"-map", "0",
+		"-c", "copy",
+		"-af", strings.Join(filters, ","),
+		"-c:a", encoder,
+	}
+	if d.NormalizeLoudness {
+		// loudnorm upsamples to 192 kHz, which few players expect
+		sampleRate := "44100"
+		if ext == ".opus" {
+			sampleRate = "48000" // The only rate Opus encodes
+		}
+		args = append(args, "-ar", sampleRate)
+	}
+	switch {
+	case ext == ".flac" || ext == ".wav":
+		// Lossless, no bitrate
+	case d.AudioQuality == "best" && ext == ".mp3":
+		args = append(args, "-q:a", "0")
+	case d.AudioQuality == "best":
+		args = append(args, "-b:a", "320k")
+	default:
+		args = append(args, "-b:a", strings.ToLower(d.audioQuality()))
+	}
+	args = append(args, d.tagArgs(filePath)...)
+
+	return d.rewrite(ctx, filePath, args)
+}
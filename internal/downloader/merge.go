@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// mergeFormat is the common format every track is converted to before
+// merging, so tracks with different sample rates or channels line up
+const mergeFormat = "aformat=sample_fmts=fltp:sample_rates=44100:channel_layouts=stereo"
+
+// mergeBatch is how many tracks each step of a long merge appends. Progress
+// is saved after every step, so an interrupted merge resumes from there.
+const mergeBatch = 8
+
+// mergeState is the progress of a long merge, saved next to its output
+type mergeState struct {
+	Inputs    []string      `json:"inputs"`
+	Crossfade time.Duration `json:"crossfade"`
+	Merged    int           `json:"merged"`  // Inputs already in the partial mix
+	Partial   string        `json:"partial"` // File name of the partial mix
+}
+
+// Merge concatenates audio files, in order, into a single MP3 and returns
+// its duration. With a crossfade, consecutive tracks overlap by that long.
+//
+// Long merges are built in steps, each into a new lossless partial mix next
+// to outPath. If one is interrupted, merging the same inputs again continues
+// from the last completed step.
+func (d *Downloader) Merge(ctx context.Context, inputs []string, outPath string, crossfade time.Duration) (time.Duration, error) {
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("nothing to merge")
+	}
+
+	if len(inputs) <= mergeBatch {
+		if err := d.mergeInto(ctx, inputs, outPath, crossfade, d.mp3Args()); err != nil {
+			return 0, fmt.Errorf("merge failed: %w", err)
+		}
+		return d.probeDuration(ctx, outPath)
+	}
+
+	base := strings.TrimSuffix(outPath, filepath.Ext(outPath))
+	dir := filepath.Dir(outPath)
+	statePath := base + ".merge.json"
+
+	// A step writes a partial mix of its own and only then is it recorded,
+	// so a step interrupted at any point is simply done again
+	state := loadMergeState(statePath, dir, inputs, crossfade)
+	for state.Merged < len(inputs) {
+		end := min(state.Merged+mergeBatch, len(inputs))
+		var batch []string
+		if state.Merged > 0 {
+			batch = append(batch, filepath.Join(dir, state.Partial))
+		}
+		batch = append(batch, inputs[state.Merged:end]...)
+
+		partialPath := fmt.Sprintf("%s.partial-%d.flac", base, end)
+		if err := d.mergeInto(ctx, batch, partialPath, crossfade, []string{"-c:a", "flac"}); err != nil {
+			return 0, fmt.Errorf("merge failed after %d of %d tracks: %w", state.Merged, len(inputs), err)
+		}
+		previous := state.Partial
+		state.Merged, state.Partial = end, filepath.Base(partialPath)
+		if err := saveMergeState(statePath, state); err != nil {
+			return 0, err
+		}
+		if previous != "" {
+			os.Remove(filepath.Join(dir, previous))
+		}
+	}
+
+	args := append([]string{"-y", "-v", "error", "-i", filepath.Join(dir, state.Partial)}, d.mp3Args()...)
+	if err := d.rewrite(ctx, outPath, args); err != nil {
+		return 0, fmt.Errorf("merge failed: %w", err)
+	}
+	removePartials(base)
+	os.Remove(statePath)
+
+	return d.probeDuration(ctx, outPath)
+}
+
+// mergeInto joins inputs into outPath, encoded with the given codec args
+func (d *Downloader) mergeInto(ctx context.Context, inputs []string, outPath string, crossfade time.Duration, codecArgs []string) error {
+	args := []string{"-y", "-v", "error"}
+	for _, input := range inputs {
+		args = append(args, "-i", input)
+	}
+
+	var filter strings.Builder
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[%d:a]%s[a%d];", i, mergeFormat, i)
+	}
+	if crossfade > 0 && len(inputs) > 1 {
+		// Chain pairwise: each fade's output is the next fade's first input
+		prev := "a0"
+		for i := 1; i < len(inputs); i++ {
+			if i > 1 {
+				filter.WriteString(";")
+			}
+			next := fmt.Sprintf("x%d", i)
+			if i == len(inputs)-1 {
+				next = "out"
+			}
+			fmt.Fprintf(&filter, "[%s][a%d]acrossfade=d=%.3f:c1=tri:c2=tri[%s]", prev, i, crossfade.Seconds(), next)
+			prev = next
+		}
+	} else {
+		for i := range inputs {
+			fmt.Fprintf(&filter, "[a%d]", i)
+		}
+		fmt.Fprintf(&filter, "concat=n=%d:v=0:a=1[out]", len(inputs))
+	}
+
+	args = append(args,
+		"-filter_complex", filter.String(),
+		"-map", "[out]",
+	)
+	args = append(args, codecArgs...)
+	return d.rewrite(ctx, outPath, args)
+}
+
+// mp3Args returns the ffmpeg args encoding a merged mix
+func (d *Downloader) mp3Args() []string {
+	return append([]string{"-c:a", "libmp3lame", "-b:a", "192k"}, d.id3Args()...)
+}
+
+// loadMergeState returns the saved progress of merging inputs, or a fresh
+// state if there is none, or it was for other inputs or a lost partial mix
+func loadMergeState(statePath, dir string, inputs []string, crossfade time.Duration) *mergeState {
+	fresh := &mergeState{Inputs: inputs, Crossfade: crossfade}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return fresh
+	}
+	var state mergeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fresh
+	}
+	if !slices.Equal(state.Inputs, inputs) || state.Crossfade != crossfade || state.Merged > len(inputs) {
+		return fresh
+	}
+	if state.Merged > 0 {
+		if state.Partial == "" || state.Partial != filepath.Base(state.Partial) {
+			return fresh
+		}
+		if _, err := os.Stat(filepath.Join(dir, state.Partial)); err != nil {
+			return fresh
+		}
+	}
+	return &state
+}
+
+// saveMergeState records a merge's progress. The file is replaced in one
+// rename, so an interrupted save leaves the previous progress.
+func saveMergeState(statePath string, state *mergeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save merge progress: %w", err)
+	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save merge progress: %w", err)
+	}
+	return nil
+}
+
+// removePartials removes the partial mixes of a merge into base, including
+// any an interrupted step left behind
+func removePartials(base string) {
+	entries, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(base) + ".partial-"
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".flac") {
+			os.Remove(filepath.Join(filepath.Dir(base), name))
+		}
+	}
+}
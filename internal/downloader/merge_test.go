@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMergeState(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "set.merge.json")
+	inputs := []string{"a.mp3", "b.mp3", "c.mp3"}
+
+	saved := &mergeState{Inputs: inputs, Crossfade: time.Second, Merged: 2, Partial: "set.partial-2.flac"}
+	if err := saveMergeState(statePath, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	// The partial mix of the last recorded step is gone
+	if state := loadMergeState(statePath, dir, inputs, time.Second); state.Merged != 0 {
+		t.Errorf("resumed at %d without the partial mix, want a fresh merge", state.Merged)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, saved.Partial), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := loadMergeState(statePath, dir, inputs, time.Second)
+	if state.Merged != 2 || state.Partial != saved.Partial {
+		t.Errorf("resumed at %d from %q, want 2 from %q", state.Merged, state.Partial, saved.Partial)
+	}
+
+	// A later step's partial mix, written but not recorded, isn't used
+	if err := os.WriteFile(filepath.Join(dir, "set.partial-3.flac"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if state := loadMergeState(statePath, dir, inputs, time.Second); state.Partial != saved.Partial {
+		t.Errorf("resumed from %q, want the recorded %q", state.Partial, saved.Partial)
+	}
+
+	if state := loadMergeState(statePath, dir, inputs[:2], time.Second); state.Merged != 0 {
+		t.Errorf("resumed at %d for other inputs, want a fresh merge", state.Merged)
+	}
+
+	removePartials(filepath.Join(dir, "set"))
+	for _, name := range []string{"set.partial-2.flac", "set.partial-3.flac"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", name, err)
+		}
+	}
+}
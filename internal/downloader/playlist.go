@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// youTubeMusicPlaylistRegex matches YouTube Music playlist and album URLs.
+// Album pages (browse/MPREb_...) are resolved to their playlist by yt-dlp.
+var youTubeMusicPlaylistRegex = regexp.MustCompile(`music\.youtube\.com/(playlist\?(.*&)?list=|browse/)[a-zA-Z0-9_-]+`)
+
+// YouTubePlaylist is a YouTube (Music) playlist or album
+type YouTubePlaylist struct {
+	Title   string
+	Entries []Candidate
+}
+
+// IsYouTubeMusicPlaylistURL checks if a string is a YouTube Music playlist
+// or album URL
+func IsYouTubeMusicPlaylistURL(s string) bool {
+	return youTubeMusicPlaylistRegex.MatchString(s)
+}
+
+// GetYouTubePlaylist lists a playlist's videos without downloading them.
+// Entries link to YouTube Music, whose downloads carry its music metadata.
+func (d *Downloader) GetYouTubePlaylist(ctx context.Context, url string) (*YouTubePlaylist, error) {
+	args := []string{
+		url,
+		"--flat-playlist",
+		"--dump-single-json",
+		"--no-warnings",
+	}
+	args = append(args, d.cookieArgs()...)
+
+	output, err := d.ytdlp(ctx, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist: %w", err)
+	}
+
+	var info struct {
+		Title   string `json:"title"`
+		Entries []struct {
+			ID       string  `json:"id"`
+			Title    string  `json:"title"`
+			Duration float64 `json:"duration"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("unexpected yt-dlp output: %w", err)
+	}
+
+	playlist := &YouTubePlaylist{Title: info.Title}
+	for _, entry := range info.Entries {
+		if entry.ID == "" {
+			continue
+		}
+		playlist.Entries = append(playlist.Entries, Candidate{
+			URL:      "https://music.youtube.com/watch?v=" + entry.ID,
+			Title:    entry.Title,
+			Duration: int(entry.Duration),
+		})
+	}
+	if len(playlist.Entries) == 0 {
+		return nil, fmt.Errorf("playlist is empty or unavailable")
+	}
+	return playlist, nil
+}
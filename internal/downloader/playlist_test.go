@@ -0,0 +1,33 @@
+package downloader
+
+import "testing"
+
+func TestIsYouTubeMusicPlaylistURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://music.youtube.com/playlist?list=PLw-VjHDlEOgvtnnnqWlTqByAtC7tXBg6D", true},
+		{"https://music.youtube.com/playlist?list=OLAK5uy_kmFYQg0MTZ8Pq2Fv8gnP6pBSVVX-Ly0Bo", true},
+		{"https://music.youtube.com/playlist?si=abc123&list=PLw-VjHDlEOgvtnnnqWlTqByAtC7tXBg6D", true},
+		{"https://music.youtube.com/playlist?list=RDCLAK5uy_k&feature=share", true},
+		{"https://music.youtube.com/browse/MPREb_4pL8gzRtw1p", true},
+		{"http://music.youtube.com/browse/MPREb_4pL8gzRtw1p", true},
+		{"music.youtube.com/playlist?list=PLw-VjHDlEOgvtnnnqWlTqByAtC7tXBg6D", true},
+
+		{"https://music.youtube.com/watch?v=K0HSD_i2DvA", false},
+		{"https://music.youtube.com/watch?v=K0HSD_i2DvA&list=RDAMVMK0HSD_i2DvA", false},
+		{"https://www.youtube.com/playlist?list=PLw-VjHDlEOgvtnnnqWlTqByAtC7tXBg6D", false},
+		{"https://www.youtube.com/watch?v=K0HSD_i2DvA", false},
+		{"https://music.youtube.com/", false},
+		{"https://music.youtube.com/playlist?list=", false},
+		{"https://music.youtube.com/browse/", false},
+		{"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M", false},
+		{"Daft Punk - Around The World", false},
+	}
+	for _, tt := range tests {
+		if got := IsYouTubeMusicPlaylistURL(tt.url); got != tt.want {
+			t.Errorf("IsYouTubeMusicPlaylistURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
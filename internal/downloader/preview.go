@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Preview plays the start of a video's audio with ffplay, without saving it
+func (d *Downloader) Preview(ctx context.Context, videoURL string, length time.Duration) error {
+	ffplayPath, err := d.ffmpegTool("ffplay")
+	if err != nil {
+		return err
+	}
+
+	streamURL, err := d.streamURL(ctx, videoURL, "bestaudio/best")
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, ffplayPath,
+		"-nodisp",
+		"-autoexit",
+		"-loglevel", "quiet",
+		"-t", fmt.Sprintf("%.0f", length.Seconds()),
+		streamURL,
+	)
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("ffplay failed: %w", err)
+	}
+	return nil
+}
+
+// streamURL returns the direct URL of a video's stream in the given
+// yt-dlp format, for ffmpeg tools to read only the part they need
+func (d *Downloader) streamURL(ctx context.Context, videoURL, format string) (string, error) {
+	cmd := d.ytdlp(ctx, append([]string{
+		"-g",
+		"-f", format,
+		"--no-playlist",
+		"--no-warnings",
+		videoURL,
+	}, d.cookieArgs()...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stream URL: %w", err)
+	}
+
+	// A format combining separate streams prints one URL per line
+	url, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if url == "" {
+		return "", fmt.Errorf("no stream found for %s", videoURL)
+	}
+	return url, nil
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ffmpegTool finds a tool that ships with ffmpeg, such as ffprobe,
+// preferring the one installed next to ffmpeg
+func (d *Downloader) ffmpegTool(name string) (string, error) {
+	path := filepath.Join(filepath.Dir(d.ffmpegCmd[0]), name)
+	if _, err := exec.LookPath(path); err == nil {
+		return path, nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in PATH: %w", name, err)
+	}
+	return path, nil
+}
+
+// ffprobe runs ffprobe and returns its trimmed output
+func (d *Downloader) ffprobe(ctx context.Context, args ...string) (string, error) {
+	ffprobePath, err := d.ffmpegTool("ffprobe")
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, ffprobePath, append([]string{"-v", "error"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// probeDuration returns the length of an audio file
+func (d *Downloader) probeDuration(ctx context.Context, filePath string) (time.Duration, error) {
+	output, err := d.ffprobe(ctx,
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filePath,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	seconds, err := strconv.ParseFloat(output, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ffprobe output %q", output)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// probeFormat returns a file's container format as named by ffprobe,
+// e.g. "mp3" or "matroska,webm"
+func (d *Downloader) probeFormat(ctx context.Context, filePath string) (string, error) {
+	return d.ffprobe(ctx,
+		"-show_entries", "format=format_name",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filePath,
+	)
+}
+
+// Verify decodes an audio file in full and returns an error if it's broken
+func (d *Downloader) Verify(ctx context.Context, filePath string) error {
+	cmd := d.ffmpeg(ctx, "-v", "error", "-i", filePath, "-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s is broken: %w", filepath.Base(filePath), err)
+	}
+	if msg := strings.TrimSpace(string(output)); msg != "" {
+		return fmt.Errorf("%s is broken: %s", filepath.Base(filePath), msg)
+	}
+	return nil
+}
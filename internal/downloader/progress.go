@@ -0,0 +1,110 @@
+package downloader
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Progress is a progress update of a download
+type Progress struct {
+	Percent float64 // Of the whole job, from searching to the finished file
+	Status  string  // What's happening, e.g. "Searching YouTube..."
+
+	// Set while yt-dlp downloads, 0 where it doesn't know yet
+	Speed      float64       // Bytes per second
+	ETA        time.Duration // Left until the download is done
+	Downloaded int64         // Bytes
+	Total      int64         // Bytes, estimated for fragmented downloads
+}
+
+// ProgressCallback is called with download progress updates
+type ProgressCallback func(Progress)
+
+// Where the stages of a job start, in percent of the whole job. Searching
+// comes first, and the download itself takes up most of the bar.
+const (
+	downloadStart    = 15
+	postprocessStart = 95 // Converting, tagging and cutting with ffmpeg
+)
+
+// progressLineRegex matches yt-dlp's --newline progress lines, e.g.
+// "[download]  45.2% of ~  5.23MiB at    1.23MiB/s ETA 00:03"
+var progressLineRegex = regexp.MustCompile(`^\[download\]\s+(\d+(?:\.\d+)?)%\s+of\s+~?\s*(\d+(?:\.\d+)?)([KMGT]?i?B)(?:\s+in\s+[\d:]+)?(?:\s+at\s+(\d+(?:\.\d+)?)([KMGT]?i?B)/s)?(?:\s+ETA\s+(\d+(?::\d+)+))?`)
+
+// fragmentRegex matches the fragment counter of fragmented (e.g. DASH)
+// downloads, e.g. "(frag 3/40)"
+var fragmentRegex = regexp.MustCompile(`\(frag (\d+)/(\d+)\)`)
+
+// postprocessRegex matches yt-dlp's post-processing steps, e.g.
+// "[ExtractAudio] Destination: song.mp3"
+var postprocessRegex = regexp.MustCompile(`^\[(ExtractAudio|Metadata|EmbedThumbnail|ThumbnailsConvertor|SponsorBlock|ModifyChapters|FixupM4a)\]`)
+
+// postprocessStatus describes each post-processing step
+var postprocessStatus = map[string]string{
+	"ExtractAudio":        "Converting...",
+	"Metadata":            "Tagging...",
+	"EmbedThumbnail":      "Embedding cover art...",
+	"ThumbnailsConvertor": "Embedding cover art...",
+	"SponsorBlock":        "Cutting segments...",
+	"ModifyChapters":      "Cutting segments...",
+	"FixupM4a":            "Converting...",
+}
+
+// sizeUnits are the byte multiples of yt-dlp's size units
+var sizeUnits = map[string]float64{
+	"B":   1,
+	"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+}
+
+// parseLine turns a line of yt-dlp output into a progress update of the
+// whole job, if it is one
+func parseLine(line string) (Progress, bool) {
+	if m := postprocessRegex.FindStringSubmatch(line); m != nil {
+		return Progress{Percent: postprocessStart, Status: postprocessStatus[m[1]]}, true
+	}
+
+	p, ok := parseProgress(line)
+	if !ok {
+		return Progress{}, false
+	}
+	p.Percent = downloadStart + p.Percent*(postprocessStart-downloadStart)/100
+	p.Status = "Downloading..."
+	return p, true
+}
+
+// parseProgress parses a yt-dlp progress line into the download's own
+// percentage, speed, ETA and sizes
+func parseProgress(line string) (Progress, bool) {
+	m := progressLineRegex.FindStringSubmatch(line)
+	if m == nil {
+		return Progress{}, false
+	}
+
+	var p Progress
+	p.Percent, _ = strconv.ParseFloat(m[1], 64)
+	if size, err := strconv.ParseFloat(m[2], 64); err == nil {
+		p.Total = int64(size * sizeUnits[m[3]])
+	}
+	if m[4] != "" {
+		if speed, err := strconv.ParseFloat(m[4], 64); err == nil {
+			p.Speed = speed * sizeUnits[m[5]]
+		}
+	}
+	if m[6] != "" {
+		p.ETA = time.Duration(parseDuration(m[6])) * time.Second
+	}
+
+	// Some yt-dlp versions count the percentage per fragment, starting over
+	// with each one, so it's taken as the share of the current fragment
+	if f := fragmentRegex.FindStringSubmatch(line); f != nil {
+		index, _ := strconv.Atoi(f[1])
+		count, _ := strconv.Atoi(f[2])
+		if count > 0 && index > 0 {
+			p.Percent = (float64(index-1) + p.Percent/100) / float64(count) * 100
+		}
+	}
+	p.Downloaded = int64(float64(p.Total) * p.Percent / 100)
+	return p, true
+}
@@ -0,0 +1,134 @@
+package downloader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		ok      bool
+		percent float64
+		total   float64 // Bytes
+		speed   float64 // Bytes per second
+		eta     time.Duration
+	}{
+		{
+			name:    "downloading",
+			line:    "[download]  45.2% of    5.23MiB at    1.23MiB/s ETA 00:03",
+			ok:      true,
+			percent: 45.2, total: 5.23 * (1 << 20), speed: 1.23 * (1 << 20), eta: 3 * time.Second,
+		},
+		{
+			name:    "estimated size",
+			line:    "[download]  10.0% of ~ 80.00MiB at  512.00KiB/s ETA 02:30",
+			ok:      true,
+			percent: 10, total: 80 * (1 << 20), speed: 512 * (1 << 10), eta: 150 * time.Second,
+		},
+		{
+			name:    "done",
+			line:    "[download] 100% of    3.52MiB in 00:00:03 at 1.17MiB/s",
+			ok:      true,
+			percent: 100, total: 3.52 * (1 << 20), speed: 1.17 * (1 << 20),
+		},
+		{
+			name:    "unknown speed and ETA",
+			line:    "[download]  12.5% of   10.00MiB at  Unknown B/s ETA Unknown",
+			ok:      true,
+			percent: 12.5, total: 10 * (1 << 20),
+		},
+		{
+			name:    "fragment",
+			line:    "[download]  45.0% of ~ 80.00MiB at    2.00MiB/s ETA 00:40 (frag 3/40)",
+			ok:      true,
+			percent: 6.125, total: 80 * (1 << 20), speed: 2 * (1 << 20), eta: 40 * time.Second,
+		},
+		{
+			// A per-fragment percentage starting over adds to the
+			// fragments already done
+			name:    "fragment counter reset",
+			line:    "[download]   2.0% of ~ 80.00MiB at    2.00MiB/s ETA 00:40 (frag 21/40)",
+			ok:      true,
+			percent: 50.05, total: 80 * (1 << 20), speed: 2 * (1 << 20), eta: 40 * time.Second,
+		},
+		{
+			// Far into an early fragment is still early in the download
+			name:    "fragment nearly done",
+			line:    "[download]  90.0% of ~ 80.00MiB at    2.00MiB/s ETA 00:40 (frag 2/40)",
+			ok:      true,
+			percent: 4.75, total: 80 * (1 << 20), speed: 2 * (1 << 20), eta: 40 * time.Second,
+		},
+		{
+			name:    "first fragment",
+			line:    "[download]   0.5% of ~ 80.00MiB at    2.00MiB/s ETA 01:20 (frag 1/40)",
+			ok:      true,
+			percent: 0.0125, total: 80 * (1 << 20), speed: 2 * (1 << 20), eta: 80 * time.Second,
+		},
+		{name: "destination", line: "[download] Destination: /tmp/song.webm"},
+		{name: "resuming", line: "[download] Resuming download at byte 1048576"},
+		{name: "post-processing", line: "[ExtractAudio] Destination: /tmp/song.mp3"},
+		{name: "warning", line: "WARNING: [youtube] Falling back to generic n function search"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := parseProgress(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseProgress() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(p.Percent-tt.percent) > 0.01 {
+				t.Errorf("Percent = %v, want %v", p.Percent, tt.percent)
+			}
+			if math.Abs(float64(p.Total)-tt.total) > 1 {
+				t.Errorf("Total = %v, want %v", p.Total, tt.total)
+			}
+			if math.Abs(p.Speed-tt.speed) > 1 {
+				t.Errorf("Speed = %v, want %v", p.Speed, tt.speed)
+			}
+			if p.ETA != tt.eta {
+				t.Errorf("ETA = %v, want %v", p.ETA, tt.eta)
+			}
+			if want := int64(float64(p.Total) * tt.percent / 100); math.Abs(float64(p.Downloaded-want)) > 1 {
+				t.Errorf("Downloaded = %v, want %v", p.Downloaded, want)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		ok      bool
+		percent float64
+		status  string
+	}{
+		{"[download]   0.0% of    5.00MiB at  Unknown B/s ETA Unknown", true, downloadStart, "Downloading..."},
+		{"[download]  50.0% of    5.00MiB at    1.00MiB/s ETA 00:02", true, 55, "Downloading..."},
+		{"[download] 100% of    5.00MiB in 00:00:05 at 1.00MiB/s", true, postprocessStart, "Downloading..."},
+		{"[download]   2.0% of ~ 80.00MiB at    2.00MiB/s ETA 00:40 (frag 21/40)", true, 55.04, "Downloading..."},
+		{"[ExtractAudio] Destination: /tmp/song.mp3", true, postprocessStart, "Converting..."},
+		{"[Metadata] Adding metadata to \"/tmp/song.mp3\"", true, postprocessStart, "Tagging..."},
+		{"[EmbedThumbnail] ffmpeg: Adding thumbnail to \"/tmp/song.mp3\"", true, postprocessStart, "Embedding cover art..."},
+		{"[SponsorBlock] Fetching SponsorBlock segments", true, postprocessStart, "Cutting segments..."},
+		{"[youtube] K0HSD_i2DvA: Downloading webpage", false, 0, ""},
+		{"[download] Destination: /tmp/song.webm", false, 0, ""},
+		{"", false, 0, ""},
+	}
+
+	for _, tt := range tests {
+		p, ok := parseLine(tt.line)
+		if ok != tt.ok {
+			t.Errorf("parseLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && (math.Abs(p.Percent-tt.percent) > 0.01 || p.Status != tt.status) {
+			t.Errorf("parseLine(%q) = %.1f%% %q, want %.1f%% %q", tt.line, p.Percent, p.Status, tt.percent, tt.status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// proxyMaxFailures is how many connection errors in a row take a proxy out
+// of rotation
+const proxyMaxFailures = 2
+
+// ProxyStatus is how a proxy of a ProxyPool has fared
+type ProxyStatus struct {
+	Proxy     string
+	Downloads int    // Successful downloads through the proxy
+	Failures  int    // Connection errors in a row
+	LastError string // Last connection error, if any
+}
+
+// Healthy reports whether the proxy is still in rotation
+func (s ProxyStatus) Healthy() bool {
+	return s.Failures < proxyMaxFailures
+}
+
+// ProxyPool hands out proxies round-robin, skipping those that keep failing
+// to connect. It's safe for concurrent use.
+type ProxyPool struct {
+	mu      sync.Mutex
+	proxies []ProxyStatus
+	next    int
+}
+
+// NewProxyPool creates a pool of proxy URLs, e.g. socks5://127.0.0.1:1080
+func NewProxyPool(proxies []string) *ProxyPool {
+	p := &ProxyPool{}
+	for _, proxy := range proxies {
+		p.proxies = append(p.proxies, ProxyStatus{Proxy: proxy})
+	}
+	return p
+}
+
+// take returns the next healthy proxy, or false if none is left
+func (p *ProxyPool) take() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for range p.proxies {
+		s := p.proxies[p.next]
+		p.next = (p.next + 1) % len(p.proxies)
+		if s.Healthy() {
+			return s.Proxy, true
+		}
+	}
+	return "", false
+}
+
+// record updates a proxy's status after a download through it. Only
+// connection errors count against it.
+func (p *ProxyPool) record(proxy string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.proxies {
+		s := &p.proxies[i]
+		if s.Proxy != proxy {
+			continue
+		}
+		var dlErr *DownloadError
+		switch {
+		case err == nil:
+			s.Downloads++
+			s.Failures = 0
+		case errors.As(err, &dlErr) && dlErr.Kind == KindProxy:
+			s.Failures++
+			s.LastError = dlErr.Output
+		}
+		return
+	}
+}
+
+// Status returns the status of each proxy, in list order
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ProxyStatus{}, p.proxies...)
+}
+
+// downloadViaProxy runs download through the next proxy of the pool, moving
+// on to the next one while proxies fail to connect
+func (d *Downloader) downloadViaProxy(ctx context.Context, url string, callback ProgressCallback, fresh bool) (*DownloadResult, error) {
+	if d.Proxies == nil {
+		return d.download(ctx, url, "", callback, fresh)
+	}
+
+	for {
+		proxy, ok := d.Proxies.take()
+		if !ok {
+			return nil, fmt.Errorf("no working proxy left")
+		}
+		result, err := d.download(ctx, url, proxy, callback, fresh)
+		d.Proxies.record(proxy, err)
+		if !IsProxyError(err) || ctx.Err() != nil {
+			return result, err
+		}
+	}
+}
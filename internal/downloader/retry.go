@@ -0,0 +1,37 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Retries of downloads failing with network errors
+const (
+	defaultMaxRetries = 3
+	retryBackoff      = 2 * time.Second // Wait before the first retry, doubled for each next one
+)
+
+// downloadWithRetry runs downloadViaProxy, retrying errors that look
+// transient up to MaxRetries times with exponential backoff
+func (d *Downloader) downloadWithRetry(ctx context.Context, url string, callback ProgressCallback, fresh bool) (*DownloadResult, error) {
+	wait := retryBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := d.downloadViaProxy(ctx, url, callback, fresh)
+		var dlErr *DownloadError
+		if !errors.As(err, &dlErr) || dlErr.Kind != KindTransient || attempt > d.MaxRetries {
+			return result, err
+		}
+
+		if callback != nil {
+			callback(Progress{Status: fmt.Sprintf("Retrying (%d/%d)...", attempt, d.MaxRetries)})
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,408 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LiveMode controls how live recordings are treated when picking a search result
+type LiveMode int
+
+const (
+	// LiveAuto avoids live versions unless the query asks for one
+	LiveAuto LiveMode = iota
+	// LiveAvoid always penalizes live versions
+	LiveAvoid
+	// LivePrefer favors live versions
+	LivePrefer
+)
+
+// defaultSearchCount is how many results are fetched per search by default
+const defaultSearchCount = 5
+
+// livePenalty is the score adjustment for a (un)wanted live version
+const livePenalty = 10.0
+
+// liveWordRegex matches words that mark a live recording
+var liveWordRegex = regexp.MustCompile(`(?i)\b(live|concert|tour)\b`)
+
+// cleanWordRegex matches titles of clean versions
+var cleanWordRegex = regexp.MustCompile(`(?i)\b(clean|radio edit|radio version|edited)\b`)
+
+// explicitWordRegex matches titles of explicit versions
+var explicitWordRegex = regexp.MustCompile(`(?i)\b(explicit|dirty|uncensored)\b`)
+
+// cleanBonus is the score adjustment for a clean or explicit version when
+// PreferClean is set
+const cleanBonus = 3.0
+
+// variantWordRegex matches titles of versions other than the original
+// recording, e.g. covers, remixes and hour-long loops
+var variantWordRegex = regexp.MustCompile(`(?i)\b(cover|remix|karaoke|instrumental|nightcore|slowed|sped up|8d audio|\d+\s*hours?|loop(ed)?)\b`)
+
+// variantPenalty is the score adjustment for a version the query didn't ask for
+const variantPenalty = 5.0
+
+// durationWindow is how far off the expected duration, as a fraction of it,
+// a result may be without DurationTolerance. Further off ones are only
+// picked if no result is closer.
+const durationWindow = 0.2
+
+// durationWeight scales how much being off the expected duration costs a
+// candidate. Being durationFitRange or more off costs it all.
+const durationWeight = 3.0
+
+// liveQueryRegex matches a query that explicitly asks for a live version
+var liveQueryRegex = regexp.MustCompile(`(?i)\blive\b`)
+
+// matchWeight scales the query/title match in a candidate's score
+const matchWeight = 3.0
+
+// wordRegex matches the words compared between query and title
+var wordRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// ErrLowMatch is returned when the best result is below the MinMatch threshold
+var ErrLowMatch = errors.New("no confident match")
+
+// ErrNoResults is returned when a search finds nothing
+var ErrNoResults = errors.New("no results found")
+
+// ErrDurationMismatch is returned when no result is close to the expected duration
+var ErrDurationMismatch = errors.New("no result with the expected duration")
+
+// SearchHint carries what is already known about the song being searched
+type SearchHint struct {
+	Duration int    // Expected duration in seconds, 0 if unknown
+	ISRC     string // Recording code, looked up before searching by name
+}
+
+// trustedChannelRegex matches channels that upload official audio: YouTube
+// Music's auto-generated "Artist - Topic" channels and VEVO
+var trustedChannelRegex = regexp.MustCompile(`(?i) - Topic$|VEVO$`)
+
+// Candidate is a single YouTube search result
+type Candidate struct {
+	URL      string
+	Title    string
+	Channel  string
+	Duration int // seconds
+	Score    float64
+	Match    float64 // Share of query words found in the title, 0..1
+
+	// Score components, for reviewing matches
+	Rank         int     // 1-based position in YouTube's results
+	Live         float64 // Adjustment for a (un)wanted live version
+	Variant      float64 // Adjustment for an unwanted cover, remix, loop, ...
+	DurationDiff int     // Seconds off the expected duration, -1 if unknown
+}
+
+// TrustedChannel reports whether the candidate was uploaded by a channel
+// known for official audio
+func (c *Candidate) TrustedChannel() bool {
+	return trustedChannelRegex.MatchString(c.Channel)
+}
+
+// Confidence rates how likely the candidate is the right video, 0..1, from
+// the title match, the duration match and the channel. An unknown duration
+// counts as half a match.
+func (c *Candidate) Confidence() float64 {
+	durationFit := 0.5
+	if c.DurationDiff >= 0 {
+		durationFit = math.Max(0, 1-float64(c.DurationDiff)/durationFitRange)
+	}
+	trust := 0.0
+	if c.TrustedChannel() {
+		trust = 1
+	}
+	return 0.6*c.Match + 0.3*durationFit + 0.1*trust
+}
+
+// durationFitRange is how many seconds off the expected duration a candidate
+// can be before its duration no longer adds to its confidence
+const durationFitRange = 30.0
+
+// Resolve searches YouTube and returns the best match without downloading it
+func (d *Downloader) Resolve(ctx context.Context, query string) (*Candidate, error) {
+	return d.ResolveWithHint(ctx, query, SearchHint{})
+}
+
+// isrcMinMatch is how much of the query an ISRC search result must match to
+// be trusted
+const isrcMinMatch = 0.5
+
+// ResolveWithHint is like Resolve, using the hint to reject wrong matches
+func (d *Downloader) ResolveWithHint(ctx context.Context, query string, hint SearchHint) (*Candidate, error) {
+	// YouTube indexes the ISRCs of official uploads, so the code alone often
+	// finds the exact recording
+	if hint.ISRC != "" {
+		if match := d.resolveISRC(ctx, query, hint); match != nil {
+			return match, nil
+		}
+	}
+
+	candidates, err := d.searchCandidates(ctx, query, d.searchCount())
+	if err != nil {
+		return nil, err
+	}
+
+	if hint.Duration > 0 && d.DurationTolerance > 0 {
+		candidates = filterByDuration(candidates, hint.Duration, d.DurationTolerance)
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%w (%s ±%.0f%%) for %q", ErrDurationMismatch, formatDuration(hint.Duration), d.DurationTolerance*100, query)
+		}
+	} else if hint.Duration > 0 {
+		// e.g. a 10-minute extended mix of a 3-minute single
+		if kept := filterByDuration(candidates, hint.Duration, durationWindow); len(kept) > 0 {
+			candidates = kept
+		} else {
+			candidates = []Candidate{closestDuration(candidates, hint.Duration)}
+		}
+	}
+
+	for i := range candidates {
+		candidates[i].DurationDiff = -1
+		if hint.Duration > 0 && candidates[i].Duration > 0 {
+			candidates[i].DurationDiff = int(math.Abs(float64(candidates[i].Duration - hint.Duration)))
+		}
+	}
+
+	best := d.selectBest(query, candidates)
+	if d.MinMatch > 0 && best.Match < d.MinMatch {
+		return nil, fmt.Errorf("%w for %q: best was %q (score %.2f < %.2f)", ErrLowMatch, query, best.Title, best.Match, d.MinMatch)
+	}
+	return &best, nil
+}
+
+// resolveISRC searches for the hint's ISRC and returns the top result if it
+// matches the query and duration, or nil to fall back to a search by name
+func (d *Downloader) resolveISRC(ctx context.Context, query string, hint SearchHint) *Candidate {
+	candidates, err := d.searchCandidates(ctx, `"`+hint.ISRC+`"`, 1)
+	if err != nil {
+		return nil
+	}
+
+	top := candidates[0]
+	top.Rank = 1
+	top.Match = matchScore(query, top.Title)
+	top.Live = d.liveScore(query, top.Title)
+	top.DurationDiff = -1
+	if hint.Duration > 0 && top.Duration > 0 {
+		top.DurationDiff = int(math.Abs(float64(top.Duration - hint.Duration)))
+		tolerance := d.DurationTolerance
+		if tolerance == 0 {
+			tolerance = durationWindow
+		}
+		if float64(top.DurationDiff) > tolerance*float64(hint.Duration) {
+			return nil
+		}
+	}
+	if top.Match < isrcMinMatch || top.Match < d.MinMatch || top.Live < 0 {
+		return nil
+	}
+	return &top
+}
+
+// searchCount returns how many results to fetch per search
+func (d *Downloader) searchCount() int {
+	if d.SearchCount > 0 {
+		return d.SearchCount
+	}
+	return defaultSearchCount
+}
+
+// searchCandidates fetches the top count search results without downloading
+// them
+func (d *Downloader) searchCandidates(ctx context.Context, query string, count int) ([]Candidate, error) {
+	args := []string{
+		fmt.Sprintf("ytsearch%d:%s", count, query),
+		"--flat-playlist",
+		"--print", "%(id)s\t%(duration)s\t%(channel)s\t%(title)s",
+		"--no-warnings",
+	}
+	// Signed in, search also finds videos only visible to the account
+	args = append(args, d.cookieArgs()...)
+
+	// yt-dlp's errors are kept to classify a failure, and echoed when
+	// debugging
+	var stderr bytes.Buffer
+	cmd := d.ytdlp(ctx, args...)
+	cmd.Stderr = &stderr
+	if d.Debug != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, d.Debug)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		// yt-dlp's output says why, e.g. YouTube's bot check
+		var lines []string
+		if out := strings.TrimSpace(stderr.String()); out != "" {
+			lines = strings.Split(out, "\n")
+		}
+		return nil, newDownloadError("ytsearch:"+query, lines, err)
+	}
+
+	var candidates []Candidate
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) < 4 || parts[0] == "" {
+			continue
+		}
+
+		duration, _ := strconv.ParseFloat(parts[1], 64)
+		candidates = append(candidates, Candidate{
+			URL:      "https://www.youtube.com/watch?v=" + strings.TrimSpace(parts[0]),
+			Channel:  strings.TrimSpace(parts[2]),
+			Title:    strings.TrimSpace(parts[3]),
+			Duration: int(duration),
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for: %s", ErrNoResults, query)
+	}
+	return candidates, nil
+}
+
+// filterByDuration drops candidates whose duration differs from the expected
+// one by more than tolerance, a fraction of it. Candidates of unknown
+// duration are kept.
+func filterByDuration(candidates []Candidate, expected int, tolerance float64) []Candidate {
+	var kept []Candidate
+	for _, c := range candidates {
+		diff := math.Abs(float64(c.Duration-expected)) / float64(expected)
+		if c.Duration == 0 || diff <= tolerance {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// closestDuration returns the candidate closest to the expected duration,
+// the first one on a tie
+func closestDuration(candidates []Candidate, expected int) Candidate {
+	best := 0
+	for i, c := range candidates {
+		if absDiff(c.Duration, expected) < absDiff(candidates[best].Duration, expected) {
+			best = i
+		}
+	}
+	return candidates[best]
+}
+
+// absDiff returns how far apart a and b are
+func absDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// formatDuration formats seconds as m:ss
+func formatDuration(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// selectBest scores the candidates and returns the highest-scoring one.
+// Earlier results win ties, so YouTube's own ranking is the baseline.
+// Candidates off the expected duration (see DurationDiff) score lower.
+func (d *Downloader) selectBest(query string, candidates []Candidate) Candidate {
+	best := 0
+	for i := range candidates {
+		c := &candidates[i]
+		c.Rank = i + 1
+		c.Match = matchScore(query, c.Title)
+		c.Live = d.liveScore(query, c.Title)
+		c.Score = float64(len(candidates) - i)
+		c.Score += c.Match * matchWeight
+		c.Score += c.Live
+		c.Variant = variantScore(query, c.Title)
+		c.Score += c.Variant
+		if c.DurationDiff > 0 {
+			c.Score -= durationWeight * math.Min(1, float64(c.DurationDiff)/durationFitRange)
+		}
+		if d.PreferClean {
+			c.Score += cleanScore(c.Title)
+		}
+
+		if c.Score > candidates[best].Score {
+			best = i
+		}
+	}
+	return candidates[best]
+}
+
+// liveScore rewards or penalizes a title that looks like a live recording,
+// depending on the live mode and whether the query itself asks for one
+func (d *Downloader) liveScore(query, title string) float64 {
+	queryWords := strings.ToLower(query)
+	isLive := false
+	for _, word := range liveWordRegex.FindAllString(title, -1) {
+		// A word the user searched for doesn't mark an unwanted version
+		// (e.g. "Tour de France")
+		if d.LiveMode != LiveAvoid && strings.Contains(queryWords, strings.ToLower(word)) {
+			continue
+		}
+		isLive = true
+	}
+
+	wantLive := d.LiveMode == LivePrefer ||
+		(d.LiveMode == LiveAuto && liveQueryRegex.MatchString(query))
+
+	switch {
+	case wantLive && liveWordRegex.MatchString(title):
+		return livePenalty
+	case !wantLive && isLive:
+		return -livePenalty
+	}
+	return 0
+}
+
+// variantScore penalizes a title marking a cover, remix, loop or other
+// version unless the query asks for it too
+func variantScore(query, title string) float64 {
+	query = strings.ToLower(query)
+	for _, word := range variantWordRegex.FindAllString(title, -1) {
+		if !strings.Contains(query, strings.ToLower(word)) {
+			return -variantPenalty
+		}
+	}
+	return 0
+}
+
+// cleanScore rewards titles of clean versions and penalizes explicit ones
+func cleanScore(title string) float64 {
+	switch {
+	case explicitWordRegex.MatchString(title):
+		return -cleanBonus
+	case cleanWordRegex.MatchString(title):
+		return cleanBonus
+	}
+	return 0
+}
+
+// matchScore returns the share of the query's words that appear in the title
+func matchScore(query, title string) float64 {
+	queryWords := wordRegex.FindAllString(strings.ToLower(query), -1)
+	if len(queryWords) == 0 {
+		return 0
+	}
+
+	titleWords := make(map[string]bool)
+	for _, word := range wordRegex.FindAllString(strings.ToLower(title), -1) {
+		titleWords[word] = true
+	}
+
+	found := 0
+	for _, word := range queryWords {
+		if titleWords[word] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(queryWords))
+}
@@ -0,0 +1,16 @@
+package downloader
+
+import "fmt"
+
+// defaultSilenceThresholdDB is the level below which audio counts as silence
+// by default
+const defaultSilenceThresholdDB = -50.0
+
+// silenceFilter is the ffmpeg filter cutting silence below
+// SilenceThresholdDB from the start and end. silenceremove only trims the
+// start without also cutting gaps in the middle, so the end is trimmed as
+// the start of the reversed audio.
+func (d *Downloader) silenceFilter() string {
+	trim := fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%gdB:start_silence=0.1", d.SilenceThresholdDB)
+	return trim + ",areverse," + trim + ",areverse"
+}
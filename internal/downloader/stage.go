@@ -0,0 +1,175 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Work folders inside the download path
+const (
+	stagingPrefix = ".dj-staging-" // Per-run folder downloads are finished in
+	partialFolder = ".dj-partial"  // Partial files kept with KeepPartial
+)
+
+// staging tracks the per-run staging folders of a downloader and its clones,
+// one per download path, so moving a file into place is a plain rename
+type staging struct {
+	mu   sync.Mutex
+	dirs map[string]string // Download path to its staging folder
+}
+
+// IsWorkDir reports whether a folder name is one of dj's staging or partial
+// file folders, whose contents are not finished downloads
+func IsWorkDir(name string) bool {
+	return strings.HasPrefix(name, stagingPrefix) || name == partialFolder
+}
+
+// stagingDir returns a new, empty folder for a single download, inside this
+// run's staging folder of the download path
+func (d *Downloader) stagingDir() (string, error) {
+	d.staging.mu.Lock()
+	defer d.staging.mu.Unlock()
+
+	runDir, ok := d.staging.dirs[d.downloadPath]
+	if !ok {
+		dir, err := os.MkdirTemp(d.downloadPath, stagingPrefix+"*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create staging folder: %w", err)
+		}
+		// yt-dlp prints the paths it writes, which are matched against this
+		if runDir, err = filepath.Abs(dir); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+		d.staging.dirs[d.downloadPath] = runDir
+	}
+
+	dir, err := os.MkdirTemp(runDir, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging folder: %w", err)
+	}
+	return dir, nil
+}
+
+// isStaged reports whether a file is still in a staging folder
+func isStaged(filePath string) bool {
+	runDir := filepath.Base(filepath.Dir(filepath.Dir(filePath)))
+	return strings.HasPrefix(runDir, stagingPrefix)
+}
+
+// Place moves a finished download, with its thumbnail and checksum, from its
+// staging folder into the download path and updates the result. Moves are
+// renames, so other processes never see a partly written or untagged file.
+// A file of the same name is never replaced: the download is discarded, the
+// result points at the file already there and ErrExists is returned. With
+// StableNames a same-named file is the same video, left broken by an earlier
+// run, and is replaced.
+func (d *Downloader) Place(result *DownloadResult) error {
+	if !isStaged(result.FilePath) {
+		return nil
+	}
+	stageDir := filepath.Dir(result.FilePath)
+	finalPath := filepath.Join(d.downloadPath, filepath.Base(result.FilePath))
+
+	// Checked again when the file is moved, but another run may have
+	// placed it since the download started
+	if !d.StableNames {
+		if _, err := os.Lstat(finalPath); err == nil {
+			return d.keepExisting(result, finalPath)
+		}
+	}
+
+	// The checksum is of the file as placed, tagged and with its cover,
+	// so later runs find it intact
+	if d.StableNames {
+		if err := writeChecksum(result.FilePath); err != nil {
+			return fmt.Errorf("failed to record checksum: %w", err)
+		}
+	}
+
+	// Sidecars first, so the audio file never appears without them
+	if result.ThumbnailPath != "" {
+		thumbPath := filepath.Join(d.downloadPath, filepath.Base(result.ThumbnailPath))
+		if err := os.Rename(result.ThumbnailPath, thumbPath); err != nil {
+			return fmt.Errorf("failed to move %s into place: %w", filepath.Base(result.ThumbnailPath), err)
+		}
+		result.ThumbnailPath = thumbPath
+	}
+	if _, err := os.Stat(result.FilePath + checksumExt); err == nil {
+		if err := os.Rename(result.FilePath+checksumExt, finalPath+checksumExt); err != nil {
+			return fmt.Errorf("failed to move %s into place: %w", filepath.Base(result.FilePath+checksumExt), err)
+		}
+	}
+
+	move := moveNoReplace
+	if d.StableNames {
+		move = os.Rename
+	}
+	if err := move(result.FilePath, finalPath); errors.Is(err, fs.ErrExist) {
+		return d.keepExisting(result, finalPath)
+	} else if err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", filepath.Base(result.FilePath), err)
+	}
+
+	result.FilePath = finalPath
+	os.RemoveAll(stageDir)
+	return nil
+}
+
+// keepExisting discards a download in favor of the file already at
+// finalPath, see Place
+func (d *Downloader) keepExisting(result *DownloadResult, finalPath string) error {
+	d.Discard(result)
+	result.FilePath = finalPath
+	result.ThumbnailPath = ""
+	result.Skipped = true
+	return fmt.Errorf("%w: %s", ErrExists, filepath.Base(finalPath))
+}
+
+// moveNoReplace renames a file, failing with fs.ErrExist instead of
+// replacing one at newPath. A hard link claims the name atomically; on file
+// systems without them (FAT32, exFAT) the name is only checked first.
+func moveNoReplace(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	switch {
+	case err == nil:
+		return os.Remove(oldPath)
+	case errors.Is(err, fs.ErrExist):
+		return err
+	}
+
+	if _, err := os.Lstat(newPath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: fs.ErrExist}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// Discard removes a download that won't be placed, with its staging folder.
+// Downloads already placed are left alone.
+func (d *Downloader) Discard(result *DownloadResult) error {
+	if !isStaged(result.FilePath) {
+		return nil
+	}
+	return os.RemoveAll(filepath.Dir(result.FilePath))
+}
+
+// Close removes this run's staging folders, with any downloads that were
+// never placed. It covers clones made with WithDownloadPath.
+func (d *Downloader) Close() error {
+	d.staging.mu.Lock()
+	defer d.staging.mu.Unlock()
+
+	var firstErr error
+	for path, dir := range d.staging.dirs {
+		if err := os.RemoveAll(dir); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(d.staging.dirs, path)
+	}
+	return firstErr
+}
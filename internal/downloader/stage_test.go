@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stagedDownload returns the result of a download staged in d, whose file
+// holds content
+func stagedDownload(t *testing.T, d *Downloader, name, content string) *DownloadResult {
+	t.Helper()
+	dir, err := d.stagingDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &DownloadResult{FilePath: path}
+}
+
+func TestPlace(t *testing.T) {
+	out := t.TempDir()
+	d := &Downloader{downloadPath: out, staging: &staging{dirs: make(map[string]string)}}
+	t.Cleanup(func() { d.Close() })
+
+	result := stagedDownload(t, d, "song.mp3", "new")
+	if err := d.Place(result); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(out, "song.mp3"); result.FilePath != want {
+		t.Errorf("FilePath = %q, want %q", result.FilePath, want)
+	}
+
+	// A second run's download of the same name leaves the first one alone
+	if err := os.WriteFile(result.FilePath, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	again := stagedDownload(t, d, "song.mp3", "newer")
+	stageDir := filepath.Dir(again.FilePath)
+	if err := d.Place(again); !errors.Is(err, ErrExists) {
+		t.Fatalf("Place over an existing file = %v, want ErrExists", err)
+	}
+	if again.FilePath != result.FilePath || !again.Skipped {
+		t.Errorf("result = %q, skipped %v, want the existing %q", again.FilePath, again.Skipped, result.FilePath)
+	}
+	if data, _ := os.ReadFile(result.FilePath); string(data) != "edited" {
+		t.Errorf("existing file = %q, want it unchanged", data)
+	}
+	if _, err := os.Stat(stageDir); !os.IsNotExist(err) {
+		t.Errorf("staging folder of the discarded download left behind: %v", err)
+	}
+}
+
+func TestMoveNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	for _, path := range []string{oldPath, newPath} {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := moveNoReplace(oldPath, newPath); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("moveNoReplace onto a file = %v, want ErrExist", err)
+	}
+	if data, _ := os.ReadFile(newPath); string(data) != "new" {
+		t.Errorf("target = %q, want it unchanged", data)
+	}
+
+	os.Remove(newPath)
+	if err := moveNoReplace(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("source still there after the move: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Metadata holds tags to write into a downloaded file.
+// Empty fields are left as they are.
+type Metadata struct {
+	Artist  string
+	Title   string
+	Album   string
+	Track   int // Track number, 0 if unknown
+	Year    int // Release year, 0 if unknown
+	Comment string
+	BPM     string // Written as TBPM
+	Key     string // Written as TKEY, in the notation the reader expects
+}
+
+// IsEmpty reports whether no tag is set
+func (m Metadata) IsEmpty() bool {
+	return m == Metadata{}
+}
+
+// ReadTags reads the tags of an audio file that Tag can write
+func (d *Downloader) ReadTags(ctx context.Context, filePath string) (Metadata, error) {
+	output, err := d.ffprobe(ctx, "-show_entries", "format_tags", "-of", "json", filePath)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var probe struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal([]byte(output), &probe); err != nil {
+		return Metadata{}, fmt.Errorf("unexpected ffprobe output: %w", err)
+	}
+
+	// Tag names differ in case between formats
+	tags := make(map[string]string)
+	for key, value := range probe.Format.Tags {
+		tags[strings.ToLower(key)] = strings.TrimSpace(value)
+	}
+
+	meta := Metadata{
+		Artist:  tags["artist"],
+		Title:   tags["title"],
+		Album:   tags["album"],
+		Comment: tags["comment"],
+		BPM:     tags["tbpm"],
+		Key:     tags["tkey"],
+	}
+	// Track numbers may be "3/12", dates "2019-05-01"
+	meta.Track, _ = strconv.Atoi(strings.SplitN(tags["track"], "/", 2)[0])
+	if date := tags["date"]; len(date) >= 4 {
+		meta.Year, _ = strconv.Atoi(date[:4])
+	}
+	return meta, nil
+}
+
+// TitleMetadata splits a video title like "Artist - Title" into tags. Titles
+// without a separator become the title alone.
+func TitleMetadata(title string) Metadata {
+	if parts := strings.SplitN(title, " - ", 2); len(parts) == 2 {
+		return Metadata{Artist: strings.TrimSpace(parts[0]), Title: strings.TrimSpace(parts[1])}
+	}
+	return Metadata{Title: strings.TrimSpace(title)}
+}
+
+// Tag writes metadata into an audio file, replacing it atomically
+func (d *Downloader) Tag(ctx context.Context, filePath string, meta Metadata) error {
+	if meta.IsEmpty() {
+		return nil
+	}
+
+	args := []string{
+		"-y",
+		"-v", "error",
+		"-i", filePath,
+		"-map", "0",
+		"-c", "copy",
+	}
+	args = append(args, d.tagArgs(filePath)...)
+	track, year := "", ""
+	if meta.Track > 0 {
+		track = strconv.Itoa(meta.Track)
+	}
+	if meta.Year > 0 {
+		year = strconv.Itoa(meta.Year)
+	}
+	for _, tag := range []struct{ key, value string }{
+		{"artist", meta.Artist},
+		{"title", meta.Title},
+		{"album", meta.Album},
+		{"track", track},
+		{"date", year},
+		{"comment", meta.Comment},
+		{"TBPM", meta.BPM},
+		{"TKEY", meta.Key},
+	} {
+		if tag.value != "" {
+			args = append(args, "-metadata", tag.key+"="+tag.value)
+		}
+	}
+
+	return d.rewrite(ctx, filePath, args)
+}
+
+// tagArgs returns the ffmpeg muxer args for writing tags into filePath:
+// the ID3 version for MP3s, none for other formats
+func (d *Downloader) tagArgs(filePath string) []string {
+	if !strings.EqualFold(filepath.Ext(filePath), ".mp3") {
+		return nil
+	}
+	return d.id3Args()
+}
+
+// id3Args returns the ffmpeg muxer args for the configured ID3 version
+func (d *Downloader) id3Args() []string {
+	switch d.ID3Version {
+	case "2.4":
+		return []string{"-id3v2_version", "4"}
+	case "1":
+		return []string{"-id3v2_version", "3", "-write_id3v1", "1"}
+	default:
+		return []string{"-id3v2_version", "3"}
+	}
+}
+
+// rewrite runs ffmpeg with the given input args into a temp file next to
+// filePath, then renames it over the original
+func (d *Downloader) rewrite(ctx context.Context, filePath string, args []string) error {
+	ext := filepath.Ext(filePath)
+	tmpPath := strings.TrimSuffix(filePath, ext) + ".tmp" + ext
+
+	cmd := d.ffmpeg(ctx, append(args, tmpPath)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(filePath), err)
+	}
+	return nil
+}
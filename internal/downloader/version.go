@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MinYtDlpVersion is the oldest yt-dlp release known to still download from
+// YouTube. Older ones typically fail with HTTP 403 errors.
+const MinYtDlpVersion = "2024.12.23"
+
+// ytdlpVersionRegex matches yt-dlp's date versions, e.g. "2025.01.15" or the
+// nightly "2025.01.15.232711"
+var ytdlpVersionRegex = regexp.MustCompile(`^(\d{4})\.(\d{2})\.(\d{2})`)
+
+// ffmpegVersionRegex matches the version in ffmpeg's banner, e.g.
+// "ffmpeg version 6.1.1-3ubuntu5 Copyright ..."
+var ffmpegVersionRegex = regexp.MustCompile(`^ffmpeg version (\S+)`)
+
+// toolVersions runs yt-dlp and ffmpeg to read their versions
+func (d *Downloader) toolVersions(ctx context.Context) error {
+	output, err := d.ytdlp(ctx, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("yt-dlp doesn't run (%s): %w", strings.Join(d.ytdlpCmd, " "), err)
+	}
+	d.YtDlpVersion = strings.TrimSpace(string(output))
+
+	output, err = d.ffmpeg(ctx, "-version").Output()
+	if err != nil {
+		return fmt.Errorf("ffmpeg doesn't run (%s): %w", strings.Join(d.ffmpegCmd, " "), err)
+	}
+	if m := ffmpegVersionRegex.FindSubmatch(output); m != nil {
+		d.FFmpegVersion = string(m[1])
+	}
+	return nil
+}
+
+// CheckYtDlpVersion returns an error describing how to update yt-dlp if it
+// is older than MinYtDlpVersion. Versions it can't read pass.
+func (d *Downloader) CheckYtDlpVersion() error {
+	m := ytdlpVersionRegex.FindStringSubmatch(d.YtDlpVersion)
+	if m == nil {
+		return nil
+	}
+	// Date versions compare as strings once cut to the date
+	if date := strings.Join(m[1:], "."); date < MinYtDlpVersion {
+		return fmt.Errorf("yt-dlp %s is older than %s and may fail with 403 errors; update it with \"yt-dlp -U\" or your package manager", d.YtDlpVersion, MinYtDlpVersion)
+	}
+	return nil
+}
+
+// ErrNoSelfUpdate means yt-dlp was installed by a package manager and has to
+// be updated with it
+var ErrNoSelfUpdate = errors.New("yt-dlp can't update itself")
+
+// pipInstallRegex matches yt-dlp -U refusing because it was installed by pip
+var pipInstallRegex = regexp.MustCompile(`(?i)installed yt-dlp with pip|wheel from PyPi`)
+
+// packageManagerRegex matches yt-dlp -U refusing to update a build it didn't
+// install itself, e.g. from Homebrew or apt
+var packageManagerRegex = regexp.MustCompile(`(?i)package manager|manual build|not installed via the release|cannot self-update|can't self-update`)
+
+// UpdateYtDlp updates yt-dlp to the latest release, with pip if that's how it
+// was installed, and reads its version again
+func (d *Downloader) UpdateYtDlp(ctx context.Context) error {
+	output, err := d.ytdlp(ctx, "-U").CombinedOutput()
+	switch {
+	case pipInstallRegex.Match(output):
+		if err := d.pipUpdate(ctx); err != nil {
+			return err
+		}
+	case packageManagerRegex.Match(output):
+		return ErrNoSelfUpdate
+	case err != nil:
+		return fmt.Errorf("yt-dlp -U failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return d.toolVersions(ctx)
+}
+
+// pipUpdate updates yt-dlp with pip: with the Python running it if it's run
+// as a module, else with pip from PATH
+func (d *Downloader) pipUpdate(ctx context.Context) error {
+	var pip []string
+	if len(d.ytdlpCmd) > 1 && strings.HasPrefix(filepath.Base(d.ytdlpCmd[0]), "python") {
+		pip = []string{d.ytdlpCmd[0], "-m", "pip"}
+	} else if path, err := exec.LookPath("pip3"); err == nil {
+		pip = []string{path}
+	} else if path, err := exec.LookPath("pip"); err == nil {
+		pip = []string{path}
+	} else {
+		return fmt.Errorf("yt-dlp was installed with pip, but pip wasn't found in PATH")
+	}
+
+	output, err := toolCommand(ctx, pip, []string{"install", "-U", "yt-dlp"}).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pip install -U yt-dlp failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckYtDlpVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		outdated bool
+	}{
+		{"2023.07.06", true},
+		{"2024.12.13", true},
+		{MinYtDlpVersion, false},
+		{"2025.01.15", false},
+		{"2025.01.15.232711", false}, // Nightly
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			ytdlp := fakeTool(t, "yt-dlp", "echo "+tt.version)
+			ffmpeg := fakeTool(t, "ffmpeg", `echo "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers"`)
+
+			d, err := NewWithTools(t.TempDir(), Tools{YtDlp: ytdlp, FFmpeg: ffmpeg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.YtDlpVersion != tt.version {
+				t.Errorf("YtDlpVersion = %q, want %q", d.YtDlpVersion, tt.version)
+			}
+			if d.FFmpegVersion != "6.1.1-3ubuntu5" {
+				t.Errorf("FFmpegVersion = %q, want %q", d.FFmpegVersion, "6.1.1-3ubuntu5")
+			}
+
+			err = d.CheckYtDlpVersion()
+			if (err != nil) != tt.outdated {
+				t.Fatalf("CheckYtDlpVersion() = %v, want outdated %v", err, tt.outdated)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.version) {
+				t.Errorf("error %q doesn't name the installed version", err)
+			}
+		})
+	}
+}
+
+func TestNewWithToolsBrokenYtDlp(t *testing.T) {
+	ytdlp := fakeTool(t, "yt-dlp", "exit 1")
+	ffmpeg := fakeTool(t, "ffmpeg", `echo "ffmpeg version 6.1.1"`)
+
+	if _, err := NewWithTools(t.TempDir(), Tools{YtDlp: ytdlp, FFmpeg: ffmpeg}); err == nil {
+		t.Error("NewWithTools() with a yt-dlp that doesn't run succeeded")
+	}
+}
@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// WaveformOptions controls the look of a waveform image
+type WaveformOptions struct {
+	Width  int
+	Height int
+	Color  string // Any ffmpeg color, e.g. "#1e90ff" or "white"
+}
+
+// GenerateWaveform draws an audio file's waveform into <name>.waveform.png
+// next to it and returns the image path
+func (d *Downloader) GenerateWaveform(ctx context.Context, filePath string, opts WaveformOptions) (string, error) {
+	pngPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".waveform.png"
+
+	filter := fmt.Sprintf("[0:a]showwavespic=s=%dx%d:colors=%s", opts.Width, opts.Height, opts.Color)
+	cmd := d.ffmpeg(ctx,
+		"-y",
+		"-v", "error",
+		"-i", filePath,
+		"-filter_complex", filter,
+		"-frames:v", "1",
+		pngPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return pngPath, nil
+}
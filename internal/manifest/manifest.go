@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the manifest's name in the output directory
+const FileName = ".dj-manifest.json"
+
+// version is the manifest format version
+const version = 1
+
+// Entry records one downloaded song
+type Entry struct {
+	Query      string    `json:"query"`
+	SpotifyID  string    `json:"spotify_id,omitempty"`
+	VideoID    string    `json:"video_id,omitempty"`
+	File       string    `json:"file"` // Relative to the output directory
+	Downloaded time.Time `json:"downloaded"`
+}
+
+// Manifest is the download history of an output directory. It is safe for
+// concurrent use.
+type Manifest struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]Entry // By Key
+}
+
+// file is the manifest as stored on disk
+type file struct {
+	Version int              `json:"version"`
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Key returns the key a song is recorded under: its Spotify track ID if it
+// has one, so a track is found however it's queried, else its query with
+// case and spacing normalized
+func Key(spotifyID, query string) string {
+	if spotifyID != "" {
+		return "spotify:" + spotifyID
+	}
+	return "query:" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// Load reads the manifest of an output directory. A directory without one
+// gets an empty manifest.
+func Load(dir string) (*Manifest, error) {
+	m := &Manifest{dir: dir, entries: make(map[string]Entry)}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	if f.Version > version {
+		return nil, fmt.Errorf("%s was written by a newer version of dj", FileName)
+	}
+	for key, entry := range f.Entries {
+		m.entries[key] = entry
+	}
+	return m, nil
+}
+
+// Lookup returns the path of the song recorded under key, if its file still
+// exists
+func (m *Manifest) Lookup(key string) (string, bool) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	m.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	path := filepath.FromSlash(entry.File)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Add records a downloaded song under key, with its file at path
+func (m *Manifest) Add(key string, entry Entry, path string) {
+	if rel, err := filepath.Rel(m.dir, path); err == nil {
+		path = rel
+	}
+	entry.File = filepath.ToSlash(path)
+	if entry.Downloaded.IsZero() {
+		entry.Downloaded = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+}
+
+// Save writes the manifest to the output directory, replacing the old one
+// only once it's fully written
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(file{Version: version, Entries: m.entries}, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dir, FileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
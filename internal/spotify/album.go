@@ -0,0 +1,84 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// AlbumInfo contains information about a Spotify album
+type AlbumInfo struct {
+	ID          string
+	Name        string
+	Artist      string
+	ReleaseDate string // YYYY, YYYY-MM or YYYY-MM-DD
+	Total       int    // Number of tracks, known before they're all fetched
+	Tracks      []TrackInfo
+}
+
+// GetAlbum gets a Spotify album with all its tracks
+func (c *Client) GetAlbum(ctx context.Context, albumID string) (*AlbumInfo, error) {
+	var info *AlbumInfo
+	err := c.EachAlbumPage(ctx, albumID, func(album *AlbumInfo, tracks []TrackInfo) error {
+		info = album
+		info.Tracks = append(info.Tracks, tracks...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// EachAlbumPage fetches an album page by page like EachPlaylistPage. Album
+// tracks don't carry their album, so it's filled in from the album itself.
+func (c *Client) EachAlbumPage(ctx context.Context, albumID string, fn func(album *AlbumInfo, tracks []TrackInfo) error) error {
+	album, err := c.client.GetAlbum(ctx, spotify.ID(albumID), c.requestOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to get album: %w", err)
+	}
+
+	artists := make([]string, len(album.Artists))
+	for i, artist := range album.Artists {
+		artists[i] = artist.Name
+	}
+	info := &AlbumInfo{
+		ID:          string(album.ID),
+		Name:        album.Name,
+		Artist:      strings.Join(artists, ", "),
+		ReleaseDate: album.ReleaseDate,
+		Total:       int(album.Tracks.Total),
+	}
+	images := convertImages(album.Images)
+
+	for {
+		pageTracks := make([]TrackInfo, 0, len(album.Tracks.Tracks))
+		for _, track := range album.Tracks.Tracks {
+			trackInfo := newTrackInfo(track)
+			trackInfo.Album = album.Name
+			trackInfo.AlbumImages = images
+			trackInfo.AlbumArtURL = trackInfo.CoverURL(0)
+			trackInfo.ReleaseDate = album.ReleaseDate
+			pageTracks = append(pageTracks, trackInfo)
+		}
+
+		// Get audio features for the page's tracks
+		if len(pageTracks) > 0 {
+			c.enrichTracksWithFeatures(ctx, pageTracks)
+		}
+		if err := fn(info, pageTracks); err != nil {
+			return err
+		}
+
+		if album.Tracks.Next == "" {
+			break
+		}
+		if err := c.client.NextPage(ctx, &album.Tracks); err != nil {
+			return fmt.Errorf("failed to get album tracks: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// topTracksMarket is asked for top tracks when the client has no market,
+// since Spotify requires one
+const topTracksMarket = "US"
+
+// ArtistInfo contains information about a Spotify artist
+type ArtistInfo struct {
+	ID   string
+	Name string
+}
+
+// GetArtist gets a Spotify artist
+func (c *Client) GetArtist(ctx context.Context, artistID string) (*ArtistInfo, error) {
+	artist, err := c.client.GetArtist(ctx, spotify.ID(artistID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get artist: %w", err)
+	}
+	return &ArtistInfo{ID: string(artist.ID), Name: artist.Name}, nil
+}
+
+// GetArtistTopTracks gets an artist's most popular tracks (up to 10) in the
+// client's market
+func (c *Client) GetArtistTopTracks(ctx context.Context, artistID string) ([]TrackInfo, error) {
+	market := c.market
+	if market == "" {
+		market = topTracksMarket
+	}
+	top, err := c.client.GetArtistsTopTracks(ctx, spotify.ID(artistID), market)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top tracks: %w", err)
+	}
+
+	tracks := make([]TrackInfo, 0, len(top))
+	for _, track := range top {
+		tracks = append(tracks, newFullTrackInfo(track))
+	}
+	if len(tracks) > 0 {
+		c.enrichTracksWithFeatures(ctx, tracks)
+	}
+	return tracks, nil
+}
+
+// GetArtistDiscography gets the tracks of all of an artist's albums, then of
+// their singles. A song released as a single and again on an album is only
+// kept once, from the album.
+func (c *Client) GetArtistDiscography(ctx context.Context, artistID string) ([]TrackInfo, error) {
+	types := []spotify.AlbumType{spotify.AlbumTypeAlbum, spotify.AlbumTypeSingle}
+	page, err := c.client.GetArtistAlbums(ctx, spotify.ID(artistID), types, append(c.requestOptions(), spotify.Limit(50))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get albums: %w", err)
+	}
+
+	var albumIDs []string
+	for {
+		for _, album := range page.Albums {
+			albumIDs = append(albumIDs, string(album.ID))
+		}
+		if page.Next == "" {
+			break
+		}
+		if err := c.client.NextPage(ctx, page); err != nil {
+			return nil, fmt.Errorf("failed to get albums: %w", err)
+		}
+	}
+
+	var tracks []TrackInfo
+	seen := make(map[string]bool)
+	for _, albumID := range albumIDs {
+		album, err := c.GetAlbum(ctx, albumID)
+		if err != nil {
+			return nil, err
+		}
+		for _, track := range album.Tracks {
+			song := strings.ToLower(track.Artist + " - " + track.Name)
+			if seen[track.ID] || seen[song] {
+				continue
+			}
+			seen[track.ID], seen[song] = true, true
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
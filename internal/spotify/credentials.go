@@ -0,0 +1,70 @@
+package spotify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Credentials holds Spotify API client credentials
+type Credentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// LoadCredentials reads client credentials from a JSON or TOML file
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds Credentials
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = parseTOMLCredentials(data, &creds)
+	} else {
+		err = json.Unmarshal(data, &creds)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return nil, fmt.Errorf("credentials file %s must set client_id and client_secret", path)
+	}
+	return &creds, nil
+}
+
+// parseTOMLCredentials parses the flat `key = "value"` subset of TOML
+func parseTOMLCredentials(data []byte, creds *Credentials) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		switch key {
+		case "client_id":
+			creds.ClientID = value
+		case "client_secret":
+			creds.ClientSecret = value
+		}
+	}
+	return scanner.Err()
+}
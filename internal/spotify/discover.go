@@ -0,0 +1,72 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// maxSeeds is how many seeds Spotify accepts per recommendations request
+const maxSeeds = 5
+
+// Recommendation selects Spotify recommendations: seed tracks, artists and
+// genres (up to five in total), and target audio features, where zero
+// values are ignored
+type Recommendation struct {
+	SeedTracks  []string // Track IDs
+	SeedArtists []string // Artist IDs
+	SeedGenres  []string
+
+	TargetBPM    float64
+	TargetEnergy float64 // 0..1
+	TargetKey    string  // Musical (Am) or Camelot (8A) notation
+
+	Limit int // Number of tracks, up to 100
+}
+
+// GetRecommendations gets tracks similar to the seeds, with audio features
+func (c *Client) GetRecommendations(ctx context.Context, rec Recommendation) ([]TrackInfo, error) {
+	seeds := spotify.Seeds{Genres: rec.SeedGenres}
+	for _, id := range rec.SeedTracks {
+		seeds.Tracks = append(seeds.Tracks, spotify.ID(id))
+	}
+	for _, id := range rec.SeedArtists {
+		seeds.Artists = append(seeds.Artists, spotify.ID(id))
+	}
+	count := len(seeds.Tracks) + len(seeds.Artists) + len(seeds.Genres)
+	if count == 0 || count > maxSeeds {
+		return nil, fmt.Errorf("recommendations need 1 to %d seeds, got %d", maxSeeds, count)
+	}
+
+	attrs := spotify.NewTrackAttributes()
+	if rec.TargetBPM > 0 {
+		attrs = attrs.TargetTempo(rec.TargetBPM)
+	}
+	if rec.TargetEnergy > 0 {
+		attrs = attrs.TargetEnergy(rec.TargetEnergy)
+	}
+	if rec.TargetKey != "" {
+		key, mode, ok := ParseKey(rec.TargetKey)
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q, use e.g. Am or 8A", rec.TargetKey)
+		}
+		attrs = attrs.TargetKey(key).TargetMode(mode)
+	}
+
+	opts := c.requestOptions()
+	if rec.Limit > 0 {
+		opts = append(opts, spotify.Limit(rec.Limit))
+	}
+	recommendations, err := c.client.GetRecommendations(ctx, seeds, attrs, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recommendations: %w", err)
+	}
+
+	tracks := make([]TrackInfo, 0, len(recommendations.Tracks))
+	for _, track := range recommendations.Tracks {
+		tracks = append(tracks, newTrackInfo(track))
+	}
+	c.enrichTracksWithFeatures(ctx, tracks)
+	return tracks, nil
+}
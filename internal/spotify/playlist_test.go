@@ -0,0 +1,207 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	testPlaylistID   = "37i9dQZF1DXcBWIGoYBM5M"
+	testPlaylistSize = 250
+	testPageSize     = 100
+)
+
+// Positions in the mocked playlist that can't be downloaded
+const (
+	testLocalTrack   = 10  // A local file
+	testRemovedTrack = 120 // A track since removed from Spotify
+)
+
+// playlistServer mocks the Spotify API for a playlist of testPlaylistSize
+// tracks, served testPageSize at a time, with a local file at
+// testLocalTrack and a removed track at testRemovedTrack. The page at
+// failOffset, if any, fails.
+func playlistServer(t *testing.T, failOffset int) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+
+	// page returns the tracks page starting at offset
+	page := func(offset int) map[string]any {
+		items := []map[string]any{}
+		for i := offset; i < offset+testPageSize && i < testPlaylistSize; i++ {
+			track := map[string]any{
+				"id":      fmt.Sprintf("track%d", i),
+				"name":    fmt.Sprintf("Track %d", i),
+				"artists": []map[string]any{{"name": "Artist"}},
+				"album":   map[string]any{"name": "Album"},
+			}
+			switch i {
+			case testLocalTrack:
+				track["id"] = ""
+				track["uri"] = "spotify:local:Artist:Album:Local+Track:180"
+			case testRemovedTrack:
+				track = nil
+			}
+			items = append(items, map[string]any{"is_local": i == testLocalTrack, "track": track})
+		}
+		var next any
+		if offset+testPageSize < testPlaylistSize {
+			next = fmt.Sprintf("%s/playlists/%s/tracks?offset=%d&limit=%d", srv.URL, testPlaylistID, offset+testPageSize, testPageSize)
+		}
+		return map[string]any{"items": items, "total": testPlaylistSize, "offset": offset, "limit": testPageSize, "next": next}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/playlists/"+testPlaylistID, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     testPlaylistID,
+			"name":   "Test Playlist",
+			"owner":  map[string]any{"display_name": "Owner"},
+			"tracks": page(0),
+		})
+	})
+	mux.HandleFunc("/playlists/"+testPlaylistID+"/tracks", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset == failOffset {
+			http.Error(w, `{"error":{"status":500,"message":"Server error"}}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(page(offset))
+	})
+	mux.HandleFunc("/audio-features", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"audio_features":[]}`))
+	})
+
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testClient returns a client of the mocked API
+func testClient(srv *httptest.Server) *Client {
+	c := newClient(nil)
+	c.client = spotify.New(srv.Client(), spotify.WithBaseURL(srv.URL+"/"))
+	return c
+}
+
+func TestEachPlaylistPage(t *testing.T) {
+	c := testClient(playlistServer(t, -1))
+
+	var pages, skipped []int
+	var ids []string
+	err := c.EachPlaylistPage(context.Background(), testPlaylistID, func(playlist *PlaylistInfo, tracks []TrackInfo) error {
+		if playlist.Name != "Test Playlist" || playlist.Total != testPlaylistSize {
+			t.Errorf("playlist = %q with %d tracks, want %q with %d", playlist.Name, playlist.Total, "Test Playlist", testPlaylistSize)
+		}
+		pages = append(pages, len(tracks))
+		skipped = append(skipped, playlist.SkippedCount)
+		for _, track := range tracks {
+			ids = append(ids, track.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{99, 99, 50}; fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("page sizes = %v, want %v", pages, want)
+	}
+	if want := []int{1, 2, 2}; fmt.Sprint(skipped) != fmt.Sprint(want) {
+		t.Errorf("SkippedCount after each page = %v, want %v", skipped, want)
+	}
+
+	var want []string
+	for i := range testPlaylistSize {
+		if i != testLocalTrack && i != testRemovedTrack {
+			want = append(want, fmt.Sprintf("track%d", i))
+		}
+	}
+	if len(ids) != len(want) {
+		t.Fatalf("got %d tracks, want %d", len(ids), len(want))
+	}
+	for i, id := range ids {
+		if id != want[i] {
+			t.Fatalf("track %d = %s, want %s: pages out of order or repeated, or unplayable tracks kept", i, id, want[i])
+		}
+	}
+}
+
+func TestEachPlaylistPageFailedPage(t *testing.T) {
+	c := testClient(playlistServer(t, 200))
+
+	fetched := 0
+	err := c.EachPlaylistPage(context.Background(), testPlaylistID, func(_ *PlaylistInfo, tracks []TrackInfo) error {
+		fetched += len(tracks)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("no error after a failed page, %d tracks fetched", fetched)
+	}
+	if fetched != 198 {
+		t.Errorf("fetched %d tracks before the failed page, want 198", fetched)
+	}
+}
+
+func TestStreamPlaylist(t *testing.T) {
+	c := testClient(playlistServer(t, -1))
+
+	playlist, tracks, errs := c.StreamPlaylist(context.Background(), testPlaylistID)
+	if playlist == nil {
+		t.Fatalf("no playlist: %v", <-errs)
+	}
+	if playlist.Name != "Test Playlist" || playlist.Total != testPlaylistSize {
+		t.Errorf("playlist = %q with %d tracks, want %q with %d", playlist.Name, playlist.Total, "Test Playlist", testPlaylistSize)
+	}
+	streamed := 0
+	for range tracks {
+		streamed++
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if streamed != testPlaylistSize-2 || playlist.SkippedCount != 2 {
+		t.Errorf("streamed %d tracks and skipped %d, want %d and 2", streamed, playlist.SkippedCount, testPlaylistSize-2)
+	}
+}
+
+func TestStreamPlaylistFailedPage(t *testing.T) {
+	c := testClient(playlistServer(t, 200))
+
+	_, tracks, errs := c.StreamPlaylist(context.Background(), testPlaylistID)
+	fetched := 0
+	for range tracks {
+		fetched++
+	}
+	if fetched != 198 {
+		t.Errorf("streamed %d tracks before the failed page, want 198", fetched)
+	}
+	if err := <-errs; err == nil {
+		t.Error("no error after a failed page")
+	}
+	if err, ok := <-errs; ok {
+		t.Errorf("second error %v, want the error channel closed", err)
+	}
+}
+
+func TestStreamPlaylistNotFound(t *testing.T) {
+	c := testClient(playlistServer(t, -1))
+
+	playlist, tracks, errs := c.StreamPlaylist(context.Background(), "missing")
+	if playlist != nil {
+		t.Errorf("playlist = %+v, want nil", playlist)
+	}
+	if _, ok := <-tracks; ok {
+		t.Error("tracks streamed for a missing playlist")
+	}
+	if err := <-errs; err == nil {
+		t.Error("no error for a missing playlist")
+	}
+}
@@ -0,0 +1,82 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// ShowInfo contains information about a Spotify podcast show
+type ShowInfo struct {
+	ID        string
+	Name      string
+	Publisher string
+	Total     int // Number of episodes, known before they're all fetched
+	Episodes  []TrackInfo
+}
+
+// GetEpisode gets a Spotify podcast episode as a track by its show
+func (c *Client) GetEpisode(ctx context.Context, episodeID string) (*TrackInfo, error) {
+	episode, err := c.client.GetEpisode(ctx, episodeID, c.requestOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get episode: %w", err)
+	}
+	info := newEpisodeInfo(*episode, episode.Show)
+	return &info, nil
+}
+
+// GetShow gets a Spotify podcast show with all its episodes, newest first
+func (c *Client) GetShow(ctx context.Context, showID string) (*ShowInfo, error) {
+	show, err := c.client.GetShow(ctx, spotify.ID(showID), c.requestOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get show: %w", err)
+	}
+
+	info := &ShowInfo{
+		ID:        string(show.ID),
+		Name:      show.Name,
+		Publisher: show.Publisher,
+		Total:     int(show.Episodes.Total),
+	}
+	for {
+		// Episodes listed by their show don't carry it
+		for _, episode := range show.Episodes.Episodes {
+			info.Episodes = append(info.Episodes, newEpisodeInfo(episode, show.SimpleShow))
+		}
+
+		if show.Episodes.Next == "" {
+			break
+		}
+		if err := c.client.NextPage(ctx, &show.Episodes); err != nil {
+			return nil, fmt.Errorf("failed to get show episodes: %w", err)
+		}
+	}
+	return info, nil
+}
+
+// newEpisodeInfo converts a Spotify episode into a TrackInfo, with its show
+// as the artist and album. Episodes are rarely on YouTube under their own
+// title, so the search query has the show's name too.
+func newEpisodeInfo(episode spotify.EpisodePage, show spotify.SimpleShow) TrackInfo {
+	info := TrackInfo{
+		ID:          string(episode.ID),
+		Name:        episode.Name,
+		Artist:      show.Name,
+		Album:       show.Name,
+		SpotifyURL:  episode.ExternalURLs["spotify"],
+		SearchQuery: fmt.Sprintf("%s %s", show.Name, episode.Name),
+		DurationMs:  int(episode.Duration_ms),
+		Explicit:    episode.Explicit,
+		ReleaseDate: episode.ReleaseDate,
+	}
+
+	// Episodes may have their own cover, else they use the show's
+	images := episode.Images
+	if len(images) == 0 {
+		images = show.Images
+	}
+	info.AlbumImages = convertImages(images)
+	info.AlbumArtURL = info.CoverURL(0)
+	return info
+}
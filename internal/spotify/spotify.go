@@ -3,17 +3,62 @@ package spotify
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
 	"regexp"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
 // Client wraps the Spotify API client
 type Client struct {
-	client *spotify.Client
+	client   *spotify.Client
+	language string
+	market   string
+
+	fallbackMarkets []string
+
+	proxy *url.URL
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithLanguage asks Spotify for names in the given language (e.g. "ja" or
+// "ja-JP") via the Accept-Language header. The catalog endpoints used here
+// (tracks, playlists, albums, artists) honor it for artist, album and track
+// names that have localized versions. A region suffix also sets the market,
+// which decides which regional release of a track is returned.
+func WithLanguage(lang string) Option {
+	return func(c *Client) {
+		c.language = lang
+		if _, region, ok := strings.Cut(lang, "-"); ok && len(region) == 2 {
+			c.market = strings.ToUpper(region)
+		}
+	}
+}
+
+// WithMarketFallback retries track lookups in these markets (e.g. "US",
+// "GB") when a track isn't available in the client's own market
+func WithMarketFallback(markets ...string) Option {
+	return func(c *Client) {
+		c.fallbackMarkets = markets
+	}
+}
+
+// WithProxy sends all requests to Spotify, including logging in, through a
+// proxy. Without it the HTTP_PROXY and HTTPS_PROXY variables are used.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *Client) {
+		c.proxy = proxy
+	}
 }
 
 // TrackInfo contains information about a Spotify track
@@ -24,134 +69,401 @@ type TrackInfo struct {
 	Album        string
 	SpotifyURL   string
 	SearchQuery  string // For YouTube search
+	ISRC         string // International Standard Recording Code, if known
+	Explicit     bool
+	DurationMs   int
 	BPM          float64
 	Key          string
+	Camelot      string // Camelot wheel code, e.g. "8A"
 	Energy       float64
 	Danceability float64
 	Valence      float64
+	AlbumImages  []Image // Album cover in the sizes Spotify offers
+	AlbumArtURL  string  // Largest album cover, "" if none
+	ReleaseDate  string  // Album release date: YYYY, YYYY-MM or YYYY-MM-DD
+}
+
+// Image is a single size of an album cover
+type Image struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// Released returns the album release date, or false if unknown. A date
+// known only to the year or month is the first day of it.
+func (t *TrackInfo) Released() (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if date, err := time.Parse(layout, t.ReleaseDate); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Duration returns the track length, or 0 if unknown
+func (t *TrackInfo) Duration() time.Duration {
+	return time.Duration(t.DurationMs) * time.Millisecond
+}
+
+// CoverURL returns the album cover closest to size pixels wide, or the
+// largest one if size is 0. It returns "" if the track has no cover.
+func (t *TrackInfo) CoverURL(size int) string {
+	best := -1
+	for i, img := range t.AlbumImages {
+		if best < 0 {
+			best = i
+			continue
+		}
+		if size <= 0 {
+			if img.Width > t.AlbumImages[best].Width {
+				best = i
+			}
+		} else if absInt(img.Width-size) < absInt(t.AlbumImages[best].Width-size) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return t.AlbumImages[best].URL
+}
+
+// absInt returns the absolute value of n
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
 
 // PlaylistInfo contains information about a Spotify playlist
 type PlaylistInfo struct {
-	ID     string
-	Name   string
-	Owner  string
-	Tracks []TrackInfo
+	ID           string
+	Name         string
+	Owner        string
+	Total        int // Number of tracks, known before they're all fetched
+	SkippedCount int // Local files and removed tracks left out so far
+	Tracks       []TrackInfo
 }
 
 // New creates a new Spotify client
-func New(clientID, clientSecret string) (*Client, error) {
+func New(clientID, clientSecret string, opts ...Option) (*Client, error) {
 	if clientID == "" || clientSecret == "" {
 		return nil, fmt.Errorf("spotify credentials not configured")
 	}
+	c := newClient(opts)
+	ctx := c.httpContext(context.Background())
 
-	config := &clientcredentials.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		TokenURL:     spotifyauth.TokenURL,
+	// Reuse the last run's token while it's valid
+	token, err := loadAppToken(clientID)
+	if err != nil {
+		config := &clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			TokenURL:     spotifyauth.TokenURL,
+		}
+		if token, err = config.Token(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get spotify token: %w", err)
+		}
+		if err := saveAppToken(clientID, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not cache spotify token: %v\n", err)
+		}
 	}
 
-	token, err := config.Token(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get spotify token: %w", err)
+	return c.connect(spotifyauth.New().Client(ctx, token)), nil
+}
+
+// newClient creates a client with the options applied, not connected yet
+func newClient(opts []Option) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// httpContext returns ctx carrying the HTTP client that oauth2 should make
+// its requests with, going through the proxy if set
+func (c *Client) httpContext(ctx context.Context) context.Context {
+	if c.proxy == nil {
+		return ctx
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(c.proxy)
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+}
+
+// connect wraps an authenticated HTTP client
+func (c *Client) connect(httpClient *http.Client) *Client {
+	if c.language != "" {
+		httpClient.Transport = &languageTransport{base: httpClient.Transport, language: c.language}
 	}
+	c.client = spotify.New(httpClient)
+	return c
+}
+
+// languageTransport sets the Accept-Language header on every request
+type languageTransport struct {
+	base     http.RoundTripper
+	language string
+}
 
-	httpClient := spotifyauth.New().Client(context.Background(), token)
-	client := spotify.New(httpClient)
+// RoundTrip implements http.RoundTripper
+func (t *languageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Language", t.language)
 
-	return &Client{client: client}, nil
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// requestOptions returns the per-request options shared by all lookups
+func (c *Client) requestOptions() []spotify.RequestOption {
+	if c.market == "" {
+		return nil
+	}
+	return []spotify.RequestOption{spotify.Market(c.market)}
 }
 
 // GetTrack gets information about a Spotify track
 func (c *Client) GetTrack(ctx context.Context, trackID string) (*TrackInfo, error) {
-	track, err := c.client.GetTrack(ctx, spotify.ID(trackID))
+	track, err := c.fetchTrack(ctx, spotify.ID(trackID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get track: %w", err)
 	}
 
-	artists := make([]string, len(track.Artists))
-	for i, artist := range track.Artists {
-		artists[i] = artist.Name
+	info := newFullTrackInfo(*track)
+
+	// Get audio features
+	features, err := c.client.GetAudioFeatures(ctx, spotify.ID(trackID))
+	if err == nil && len(features) > 0 && features[0] != nil {
+		applyFeatures(&info, features[0])
 	}
-	artistStr := strings.Join(artists, ", ")
 
-	info := &TrackInfo{
-		ID:          string(track.ID),
-		Name:        track.Name,
-		Artist:      artistStr,
-		Album:       track.Album.Name,
-		SpotifyURL:  string(track.ExternalURLs["spotify"]),
-		SearchQuery: fmt.Sprintf("%s %s", artistStr, track.Name),
+	return &info, nil
+}
+
+// SearchTrack finds the Spotify track best matching a query, such as
+// "Artist Title", with its audio features
+func (c *Client) SearchTrack(ctx context.Context, query string) (*TrackInfo, error) {
+	opts := append(c.requestOptions(), spotify.Limit(1))
+	results, err := c.client.Search(ctx, query, spotify.SearchTypeTrack, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tracks: %w", err)
+	}
+	if results.Tracks == nil || len(results.Tracks.Tracks) == 0 {
+		return nil, fmt.Errorf("no Spotify track found for %q", query)
 	}
 
-	// Get audio features
-	features, err := c.client.GetAudioFeatures(ctx, spotify.ID(trackID))
+	track := results.Tracks.Tracks[0]
+	info := newFullTrackInfo(track)
+
+	features, err := c.client.GetAudioFeatures(ctx, track.ID)
 	if err == nil && len(features) > 0 && features[0] != nil {
-		f := features[0]
-		info.BPM = float64(f.Tempo)
-		info.Key = keyToString(int(f.Key), int(f.Mode))
-		info.Energy = float64(f.Energy)
-		info.Danceability = float64(f.Danceability)
-		info.Valence = float64(f.Valence)
+		applyFeatures(&info, features[0])
 	}
 
-	return info, nil
+	return &info, nil
+}
+
+// fetchTrack gets a track, retrying in the fallback markets when it's
+// unavailable in the client's market. A track unavailable everywhere is
+// still returned, since its metadata is enough to search for it.
+func (c *Client) fetchTrack(ctx context.Context, id spotify.ID) (*spotify.FullTrack, error) {
+	track, err := c.client.GetTrack(ctx, id, c.requestOptions()...)
+	if err == nil && isAvailable(track) {
+		return track, nil
+	}
+
+	for _, market := range c.fallbackMarkets {
+		fallback, fallbackErr := c.client.GetTrack(ctx, id, spotify.Market(market))
+		if fallbackErr == nil && isAvailable(fallback) {
+			return fallback, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// isAvailable reports whether a track is playable in the market it was
+// requested for. Spotify returns no ID or is_playable=false otherwise.
+func isAvailable(track *spotify.FullTrack) bool {
+	return track.ID != "" && (track.IsPlayable == nil || *track.IsPlayable)
 }
 
 // GetPlaylist gets information about a Spotify playlist
 func (c *Client) GetPlaylist(ctx context.Context, playlistID string) (*PlaylistInfo, error) {
-	playlist, err := c.client.GetPlaylist(ctx, spotify.ID(playlistID))
+	var info *PlaylistInfo
+	err := c.EachPlaylistPage(ctx, playlistID, func(playlist *PlaylistInfo, tracks []TrackInfo) error {
+		info = playlist
+		info.Tracks = append(info.Tracks, tracks...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// EachPlaylistPage fetches a playlist page by page, calling fn with each
+// page's tracks as soon as it arrives. The playlist's Tracks are left empty.
+// Local files and removed tracks, which can't be looked up, are skipped.
+// An error returned by fn stops the fetch and is returned.
+func (c *Client) EachPlaylistPage(ctx context.Context, playlistID string, fn func(playlist *PlaylistInfo, tracks []TrackInfo) error) error {
+	playlist, err := c.client.GetPlaylist(ctx, spotify.ID(playlistID), c.requestOptions()...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get playlist: %w", err)
+		return fmt.Errorf("failed to get playlist: %w", err)
 	}
 
 	info := &PlaylistInfo{
 		ID:    string(playlist.ID),
 		Name:  playlist.Name,
 		Owner: playlist.Owner.DisplayName,
+		Total: int(playlist.Tracks.Total),
 	}
 
-	// Get all tracks (handle pagination)
-	tracks := playlist.Tracks.Tracks
-	for page := 1; ; page++ {
-		for _, item := range tracks {
-			track := item.Track
-
-			artists := make([]string, len(track.Artists))
-			for i, artist := range track.Artists {
-				artists[i] = artist.Name
-			}
-			artistStr := strings.Join(artists, ", ")
-
-			trackInfo := TrackInfo{
-				ID:          string(track.ID),
-				Name:        track.Name,
-				Artist:      artistStr,
-				Album:       track.Album.Name,
-				SpotifyURL:  string(track.ExternalURLs["spotify"]),
-				SearchQuery: fmt.Sprintf("%s %s", artistStr, track.Name),
+	// Get all tracks, page by page. NextPage replaces playlist.Tracks in
+	// place, Next link included, so each page is read exactly once.
+	for {
+		pageTracks := make([]TrackInfo, 0, len(playlist.Tracks.Tracks))
+		for _, item := range playlist.Tracks.Tracks {
+			if item.IsLocal || item.Track.ID == "" {
+				info.SkippedCount++
+				continue
 			}
-			info.Tracks = append(info.Tracks, trackInfo)
+			pageTracks = append(pageTracks, newFullTrackInfo(item.Track))
+		}
+
+		// Get audio features for the page's tracks
+		if len(pageTracks) > 0 {
+			c.enrichTracksWithFeatures(ctx, pageTracks)
+		}
+		if err := fn(info, pageTracks); err != nil {
+			return err
 		}
 
-		// Check if there are more pages
 		if playlist.Tracks.Next == "" {
 			break
 		}
+		// A failed page would silently truncate the playlist
+		if err := c.client.NextPage(ctx, &playlist.Tracks); err != nil {
+			return fmt.Errorf("failed to get playlist tracks: %w", err)
+		}
+	}
+
+	return nil
+}
 
-		err := c.client.NextPage(ctx, &playlist.Tracks)
+// StreamPlaylist yields a playlist's tracks as their pages are fetched,
+// without holding the whole playlist in memory. The playlist is returned
+// once its first page is in, or nil if it couldn't be fetched. The tracks
+// channel is closed when the playlist is done, with the playlist's
+// SkippedCount final; the error channel then receives at most one error.
+func (c *Client) StreamPlaylist(ctx context.Context, playlistID string) (*PlaylistInfo, <-chan TrackInfo, <-chan error) {
+	tracks := make(chan TrackInfo)
+	errs := make(chan error, 1)
+	ready := make(chan *PlaylistInfo, 1)
+
+	go func() {
+		defer close(errs)
+		started := false
+		err := c.EachPlaylistPage(ctx, playlistID, func(playlist *PlaylistInfo, page []TrackInfo) error {
+			if !started {
+				ready <- playlist
+				started = true
+			}
+			for _, track := range page {
+				select {
+				case tracks <- track:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		close(ready)
+		close(tracks)
 		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return <-ready, tracks, errs
+}
+
+// GetUserPlaylists lists a user's public playlists, without their tracks
+func (c *Client) GetUserPlaylists(ctx context.Context, userID string) ([]PlaylistInfo, error) {
+	page, err := c.client.GetPlaylistsForUser(ctx, userID, spotify.Limit(50))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlists: %w", err)
+	}
+
+	var playlists []PlaylistInfo
+	for {
+		for _, p := range page.Playlists {
+			playlists = append(playlists, PlaylistInfo{
+				ID:    string(p.ID),
+				Name:  p.Name,
+				Owner: p.Owner.DisplayName,
+				Total: int(p.Tracks.Total),
+			})
+		}
+
+		if page.Next == "" {
 			break
 		}
-		tracks = playlist.Tracks.Tracks
+		if err := c.client.NextPage(ctx, page); err != nil {
+			return nil, fmt.Errorf("failed to get playlists: %w", err)
+		}
 	}
+	return playlists, nil
+}
 
-	// Get audio features for all tracks in batches
-	if len(info.Tracks) > 0 {
-		c.enrichTracksWithFeatures(ctx, info.Tracks)
+// newTrackInfo converts a Spotify track into a TrackInfo without album or audio features
+func newTrackInfo(track spotify.SimpleTrack) TrackInfo {
+	artists := make([]string, len(track.Artists))
+	for i, artist := range track.Artists {
+		artists[i] = artist.Name
 	}
+	artistStr := strings.Join(artists, ", ")
 
-	return info, nil
+	return TrackInfo{
+		ID:          string(track.ID),
+		Name:        track.Name,
+		Artist:      artistStr,
+		SpotifyURL:  string(track.ExternalURLs["spotify"]),
+		SearchQuery: fmt.Sprintf("%s %s", artistStr, track.Name),
+		DurationMs:  int(track.Duration),
+		Explicit:    track.Explicit,
+	}
+}
+
+// newFullTrackInfo converts a Spotify track with its album into a TrackInfo
+// without audio features
+func newFullTrackInfo(track spotify.FullTrack) TrackInfo {
+	info := newTrackInfo(track.SimpleTrack)
+	info.Album = track.Album.Name
+	info.AlbumImages = convertImages(track.Album.Images)
+	info.AlbumArtURL = info.CoverURL(0)
+	info.ReleaseDate = track.Album.ReleaseDate
+	info.ISRC = track.ExternalIDs["isrc"]
+	return info
+}
+
+// convertImages converts Spotify images into Image values
+func convertImages(images []spotify.Image) []Image {
+	result := make([]Image, len(images))
+	for i, img := range images {
+		result[i] = Image{URL: img.URL, Width: int(img.Width), Height: int(img.Height)}
+	}
+	return result
 }
 
 // enrichTracksWithFeatures adds audio features to tracks
@@ -181,16 +493,58 @@ func (c *Client) enrichTracksWithFeatures(ctx context.Context, tracks []TrackInf
 			}
 			idx := i + j
 			if idx < len(tracks) {
-				tracks[idx].BPM = float64(f.Tempo)
-				tracks[idx].Key = keyToString(int(f.Key), int(f.Mode))
-				tracks[idx].Energy = float64(f.Energy)
-				tracks[idx].Danceability = float64(f.Danceability)
-				tracks[idx].Valence = float64(f.Valence)
+				applyFeatures(&tracks[idx], f)
 			}
 		}
 	}
 }
 
+// applyFeatures copies audio features into a TrackInfo
+func applyFeatures(info *TrackInfo, f *spotify.AudioFeatures) {
+	info.BPM = float64(f.Tempo)
+	info.SetKey(int(f.Key), int(f.Mode))
+	info.Energy = float64(f.Energy)
+	info.Danceability = float64(f.Danceability)
+	info.Valence = float64(f.Valence)
+}
+
+// SetKey sets Key and Camelot from a pitch class (0 = C) and mode
+// (1 = major, 0 = minor), as in Spotify's audio features
+func (t *TrackInfo) SetKey(key, mode int) {
+	t.Key = keyToString(key, mode)
+	t.Camelot = keyToCamelot(key, mode)
+}
+
+// ParseKey parses a key in musical (Am, F#) or Camelot (8A) notation into a
+// pitch class (0 = C) and mode (1 = major, 0 = minor)
+func ParseKey(s string) (key, mode int, ok bool) {
+	for key := 0; key < 12; key++ {
+		for mode := 0; mode <= 1; mode++ {
+			if strings.EqualFold(s, keyToCamelot(key, mode)) || s == keyToString(key, mode) {
+				return key, mode, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// keyToCamelot converts Spotify's numeric key and mode to a Camelot wheel
+// code, e.g. C major is "8B" and A minor is "8A"
+func keyToCamelot(key, mode int) string {
+	if key < 0 || key > 11 {
+		return ""
+	}
+
+	// Each step of a fifth moves one position on the wheel; a minor key
+	// shares its number with its relative major, three semitones up
+	letter := "B"
+	if mode != 1 {
+		key = (key + 3) % 12
+		letter = "A"
+	}
+	return fmt.Sprintf("%d%s", (key*7+7)%12+1, letter)
+}
+
 // keyToString converts Spotify's numeric key to a string representation
 func keyToString(key, mode int) string {
 	keys := []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
@@ -226,6 +580,26 @@ func IsSpotifyAlbumURL(s string) bool {
 	return strings.Contains(s, "spotify.com/album/") || strings.HasPrefix(s, "spotify:album:")
 }
 
+// IsSpotifyArtistURL checks if a string is a Spotify artist URL
+func IsSpotifyArtistURL(s string) bool {
+	return strings.Contains(s, "spotify.com/artist/") || strings.HasPrefix(s, "spotify:artist:")
+}
+
+// IsSpotifyEpisodeURL checks if a string is a Spotify podcast episode URL
+func IsSpotifyEpisodeURL(s string) bool {
+	return strings.Contains(s, "spotify.com/episode/") || strings.HasPrefix(s, "spotify:episode:")
+}
+
+// IsSpotifyShowURL checks if a string is a Spotify podcast show URL
+func IsSpotifyShowURL(s string) bool {
+	return strings.Contains(s, "spotify.com/show/") || strings.HasPrefix(s, "spotify:show:")
+}
+
+// IsSpotifyUserURL checks if a URL is a Spotify user profile URL
+func IsSpotifyUserURL(s string) bool {
+	return strings.Contains(s, "spotify.com/user/") || strings.HasPrefix(s, "spotify:user:")
+}
+
 // ExtractSpotifyID extracts the ID from a Spotify URL or URI
 func ExtractSpotifyID(s string) string {
 	// Handle Spotify URIs (spotify:track:xxx)
@@ -241,6 +615,10 @@ func ExtractSpotifyID(s string) string {
 		`spotify\.com/track/([a-zA-Z0-9]+)`,
 		`spotify\.com/playlist/([a-zA-Z0-9]+)`,
 		`spotify\.com/album/([a-zA-Z0-9]+)`,
+		`spotify\.com/artist/([a-zA-Z0-9]+)`,
+		`spotify\.com/episode/([a-zA-Z0-9]+)`,
+		`spotify\.com/show/([a-zA-Z0-9]+)`,
+		`spotify\.com/user/([^/?#]+)`, // User IDs aren't base62
 	}
 
 	for _, pattern := range patterns {
@@ -254,3 +632,27 @@ func ExtractSpotifyID(s string) string {
 
 	return ""
 }
+
+// bareIDRegex matches a Spotify ID on its own: 22 base62 characters
+var bareIDRegex = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// shortIDRegex matches a Spotify ID prefixed with its kind, e.g. track:ID
+var shortIDRegex = regexp.MustCompile(`^(track|playlist|album|artist|episode|show):([0-9A-Za-z]{22})$`)
+
+// IsBareID checks if a string is a Spotify ID without a URL or kind. Track,
+// playlist, album and artist IDs look the same, so it can't be looked up.
+// Only strings mixing letters and digits count, so a long word is still a
+// search query.
+func IsBareID(s string) bool {
+	return bareIDRegex.MatchString(s) && strings.ContainsAny(s, "0123456789") &&
+		strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+// ExpandShortID turns a Spotify ID prefixed with its kind (track:, playlist:,
+// album:, artist:, episode: or show:) into a Spotify URI, e.g. spotify:track:ID
+func ExpandShortID(s string) (string, bool) {
+	if !shortIDRegex.MatchString(s) {
+		return "", false
+	}
+	return "spotify:" + s, true
+}
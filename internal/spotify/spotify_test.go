@@ -0,0 +1,23 @@
+package spotify
+
+import "testing"
+
+func TestIsBareID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"4uLU6hMCjMI75M1A2tKUQC", true},
+		{"37i9dQZF1DXcBWIGoYBM5M", true},
+		{"Supercalifragilisticex", false}, // A long word is a search
+		{"1234567890123456789012", false},
+		{"4uLU6hMCjMI75M1A2tKUQ", false}, // 21 characters
+		{"track:4uLU6hMCjMI75M1A2tKUQC", false},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", false},
+	}
+	for _, tt := range tests {
+		if got := IsBareID(tt.s); got != tt.want {
+			t.Errorf("IsBareID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package spotify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
+)
+
+// RedirectURL is the OAuth callback URL. It must be added to the app's
+// Redirect URIs in the Spotify Developer Dashboard.
+const RedirectURL = "http://127.0.0.1:8888/callback"
+
+// userTokenFile is the cached user token, relative to the config dir
+const userTokenFile = "spotify-user-token.json"
+
+// appTokenFile is the cached client credentials token, relative to the
+// config dir
+const appTokenFile = "spotify-app-token.json"
+
+// appTokenMargin is how long before its expiry a cached token is replaced,
+// so it doesn't expire during a run
+const appTokenMargin = 5 * time.Minute
+
+// cachedAppToken is a client credentials token and the app it belongs to
+type cachedAppToken struct {
+	ClientID string        `json:"client_id"`
+	Token    *oauth2.Token `json:"token"`
+}
+
+// userScopes are requested once so the cached token serves every user command
+var userScopes = []string{
+	spotifyauth.ScopeUserReadRecentlyPlayed,
+	spotifyauth.ScopeUserLibraryRead,
+}
+
+// NewWithUserAuth creates a Spotify client acting on behalf of a user.
+// On first use it opens the browser for the user to log in and caches the
+// token on disk; later runs reuse and refresh the cached token.
+func NewWithUserAuth(ctx context.Context, clientID, clientSecret string, opts ...Option) (*Client, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("spotify credentials not configured")
+	}
+	c := newClient(opts)
+	ctx = c.httpContext(ctx)
+
+	auth := spotifyauth.New(
+		spotifyauth.WithClientID(clientID),
+		spotifyauth.WithClientSecret(clientSecret),
+		spotifyauth.WithRedirectURL(RedirectURL),
+		spotifyauth.WithScopes(userScopes...),
+	)
+
+	token, err := loadUserToken()
+	if err == nil && token.Expiry.Before(time.Now()) {
+		token, err = auth.RefreshToken(ctx, token)
+	}
+	if err != nil {
+		if token, err = authorizeUser(ctx, auth); err != nil {
+			return nil, err
+		}
+	}
+	if err := saveUserToken(token); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not cache spotify token: %v\n", err)
+	}
+
+	return c.connect(auth.Client(ctx, token)), nil
+}
+
+// authorizeUser runs the authorization-code flow through a local callback server
+func authorizeUser(ctx context.Context, auth *spotifyauth.Authenticator) (*oauth2.Token, error) {
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:8888")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start auth callback server: %w", err)
+	}
+
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.Token(ctx, state, r)
+		if err != nil {
+			http.Error(w, "Spotify login failed", http.StatusForbidden)
+		} else {
+			fmt.Fprintln(w, "Logged in to Spotify, you can close this window.")
+		}
+		select {
+		case results <- result{token, err}:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := auth.AuthURL(state)
+	fmt.Fprintf(os.Stderr, "Log in to Spotify in your browser:\n  %s\n", authURL)
+	openBrowser(authURL)
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			return nil, fmt.Errorf("spotify login failed: %w", res.err)
+		}
+		return res.token, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// randomState returns a random OAuth state value
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// openBrowser tries to open a URL in the default browser
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	cmd.Start()
+}
+
+// configDir returns dj's config directory, creating it if needed
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "dj")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// loadUserToken reads the cached user token
+func loadUserToken() (*oauth2.Token, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, userTokenFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		return nil, errors.New("cached token has no refresh token")
+	}
+	return &token, nil
+}
+
+// saveUserToken caches the user token, readable only by the current user
+func saveUserToken(token *oauth2.Token) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, userTokenFile), data, 0600)
+}
+
+// loadAppToken reads the cached client credentials token for an app. It
+// fails if there is none, or it's corrupt or about to expire.
+func loadAppToken(clientID string) (*oauth2.Token, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, appTokenFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var cached cachedAppToken
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	if cached.ClientID != clientID || cached.Token == nil || cached.Token.AccessToken == "" {
+		return nil, errors.New("no cached token for this app")
+	}
+	if time.Until(cached.Token.Expiry) < appTokenMargin {
+		return nil, errors.New("cached token expired")
+	}
+	return cached.Token, nil
+}
+
+// saveAppToken caches a client credentials token, readable only by the
+// current user
+func saveAppToken(clientID string, token *oauth2.Token) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cachedAppToken{ClientID: clientID, Token: token})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, appTokenFile), data, 0600)
+}
+
+// RecentlyPlayed returns the user's recently played tracks, newest first,
+// without duplicates. Spotify only remembers the last 50 plays.
+func (c *Client) RecentlyPlayed(ctx context.Context, limit int) ([]TrackInfo, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 50
+	}
+
+	items, err := c.client.PlayerRecentlyPlayedOpt(ctx, &spotify.RecentlyPlayedOptions{Limit: 50})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recently played: %w", err)
+	}
+
+	var tracks []TrackInfo
+	seen := make(map[spotify.ID]bool)
+	for _, item := range items {
+		if item.Track.ID == "" || seen[item.Track.ID] {
+			continue
+		}
+		seen[item.Track.ID] = true
+		tracks = append(tracks, newTrackInfo(item.Track))
+		if len(tracks) == limit {
+			break
+		}
+	}
+
+	if len(tracks) > 0 {
+		c.enrichTracksWithFeatures(ctx, tracks)
+	}
+	return tracks, nil
+}
+
+// GetSavedTracks returns the most recently liked limit tracks in the user's
+// Liked Songs, newest first, or all of them if limit is 0
+func (c *Client) GetSavedTracks(ctx context.Context, limit int) ([]TrackInfo, error) {
+	pageSize := 50
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+	page, err := c.client.CurrentUsersTracks(ctx, append(c.requestOptions(), spotify.Limit(pageSize))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liked songs: %w", err)
+	}
+
+	var tracks []TrackInfo
+	for {
+		pageTracks := make([]TrackInfo, 0, len(page.Tracks))
+		for _, item := range page.Tracks {
+			if limit > 0 && len(tracks)+len(pageTracks) == limit {
+				break
+			}
+			pageTracks = append(pageTracks, newFullTrackInfo(item.FullTrack))
+		}
+		if len(pageTracks) > 0 {
+			c.enrichTracksWithFeatures(ctx, pageTracks)
+		}
+		tracks = append(tracks, pageTracks...)
+
+		if page.Next == "" || (limit > 0 && len(tracks) >= limit) {
+			break
+		}
+		// A failed page would silently truncate the library
+		if err := c.client.NextPage(ctx, page); err != nil {
+			return nil, fmt.Errorf("failed to get liked songs: %w", err)
+		}
+	}
+	return tracks, nil
+}